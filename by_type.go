@@ -0,0 +1,134 @@
+package oneof
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// ByType is a collection of values of T with at most one value of each
+// option, which [MarshalFunc] encodes as a JSON object keyed by discriminator
+// value:
+//
+//	type Config struct {
+//	  Plugins oneof.ByType[Plugin] `json:"plugins"`
+//	}
+//
+// encodes like:
+//
+//	{
+//	  "plugins": {
+//	    "auth": {"issuer": "https://example.com"},
+//	    "cache": {"size": 100}
+//	  }
+//	}
+//
+// Marshaling or unmarshaling a ByType with two values of the same option
+// fails with [ErrDuplicateDiscriminatorValue]. Members are written in the order of the
+// collection, unless [json.Deterministic] is set, in which case they are
+// sorted by discriminator value. [UnmarshalFunc] decodes members in the order
+// in which they appear.
+type ByType[T any] []T
+
+// byTypeMarshalFunc creates a [json.MarshalFuncV2] for [ByType] collections of
+// T.
+func (r *Registry[T]) byTypeMarshalFunc(types typeIndex, replaceMissingTypeFunc func(any) string) *json.Marshalers {
+	return json.MarshalFuncV2(func(enc *jsontext.Encoder, s ByType[T], jsonopts json.Options) error {
+		if s == nil {
+			return json.SkipFunc
+		}
+
+		type member struct {
+			key string
+			t   T
+		}
+		members := make([]member, len(s))
+		seen := make(map[string]struct{}, len(s))
+		for i, t := range s {
+			if any(t) == nil {
+				return fmt.Errorf("cannot marshal nil element %d of %T", i, s)
+			}
+			key, ok := types.keyFor(t)
+			if !ok {
+				if replaceMissingTypeFunc == nil {
+					return ErrUnknownGoType{typ: fmt.Sprintf("%T", t)}
+				}
+				key = replaceMissingTypeFunc(t)
+			}
+			if _, ok := seen[key]; ok {
+				return ErrDuplicateDiscriminatorValue{v: key}
+			}
+			seen[key] = struct{}{}
+			members[i] = member{key: key, t: t}
+		}
+
+		if deterministic, ok := json.GetOption(jsonopts, json.Deterministic); ok && deterministic {
+			sort.Slice(members, func(i, j int) bool { return members[i].key < members[j].key })
+		}
+
+		if err := enc.WriteToken(jsontext.ObjectStart); err != nil {
+			return fmt.Errorf("failed to write object start token: %w", err)
+		}
+		for _, m := range members {
+			if err := enc.WriteToken(jsontext.String(m.key)); err != nil {
+				return fmt.Errorf("failed to write discriminator token %s: %w", m.key, err)
+			}
+			err := marshalDefault(m.t, jsonopts, func(v jsontext.Value) error {
+				return enc.WriteValue(v)
+			})
+			if err != nil {
+				return fmt.Errorf("failed to marshal t: %w", err)
+			}
+		}
+		if err := enc.WriteToken(jsontext.ObjectEnd); err != nil {
+			return fmt.Errorf("failed to write object end token: %w", err)
+		}
+		return nil
+	})
+}
+
+// byTypeUnmarshalFunc creates a [json.UnmarshalFuncV2] for [ByType]
+// collections of T.
+func (r *Registry[T]) byTypeUnmarshalFunc() *json.Unmarshalers {
+	return json.UnmarshalFuncV2(func(dec *jsontext.Decoder, ptr *ByType[T], jsonopts json.Options) error {
+		if dec.PeekKind() != '{' {
+			return json.SkipFunc
+		}
+		if _, err := dec.ReadToken(); err != nil {
+			return fmt.Errorf("failed to read object start token: %w", err)
+		}
+
+		s := ByType[T]{}
+		seen := map[string]struct{}{}
+		for dec.PeekKind() != '}' {
+			tok, err := dec.ReadToken()
+			if err != nil {
+				return fmt.Errorf("failed to read discriminator token: %w", err)
+			}
+			key := tok.String()
+			if _, ok := seen[key]; ok {
+				// Only possible with [jsontext.AllowDuplicateNames]
+				return ErrDuplicateDiscriminatorValue{v: key}
+			}
+			seen[key] = struct{}{}
+			v, err := dec.ReadValue()
+			if err != nil {
+				return fmt.Errorf("failed to read value: %w", err)
+			}
+
+			var t T
+			if err := r.decodeOption(&t, key, v.Clone(), nil, jsonopts); err != nil {
+				return err
+			}
+			s = append(s, t)
+		}
+		if _, err := dec.ReadToken(); err != nil {
+			return fmt.Errorf("failed to read object end token: %w", err)
+		}
+
+		*ptr = s
+		return nil
+	})
+}
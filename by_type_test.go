@@ -0,0 +1,86 @@
+package oneof_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/dhoelle/oneof"
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+type pluginConfig struct {
+	Plugins oneof.ByType[fmt.Stringer] `json:"plugins"`
+}
+
+func Test_ByType(t *testing.T) {
+	r := oneof.NewRegistry[fmt.Stringer]().
+		MustRegister("literal", LiteralStringer("")).
+		MustRegister("join", JoinStringer{}).
+		MustRegister("exclamation", ExclamationPointsStringer(0))
+	opts := r.JSONOptions(nil)
+
+	in := pluginConfig{Plugins: oneof.ByType[fmt.Stringer]{
+		LiteralStringer("a"),
+		JoinStringer{A: LiteralStringer("b"), B: ExclamationPointsStringer(1)},
+		ExclamationPointsStringer(2),
+	}}
+
+	t.Run("insertion order", func(t *testing.T) {
+		b, err := json.Marshal(in, opts)
+		if err != nil {
+			t.Fatalf("error marshaling: %v", err)
+		}
+		want := `{"plugins":{"literal":"a",` +
+			`"join":{"a":{"_type":"literal","_value":"b"},"b":{"_type":"exclamation","_value":1}},` +
+			`"exclamation":2}}`
+		if string(b) != want {
+			t.Errorf("got:\n%s\nwant:\n%s", b, want)
+		}
+
+		var out pluginConfig
+		if err := json.Unmarshal(b, &out, opts); err != nil {
+			t.Fatalf("error unmarshaling: %v", err)
+		}
+		if !reflect.DeepEqual(out, in) {
+			t.Errorf("got %#v, want %#v", out, in)
+		}
+	})
+
+	t.Run("deterministic", func(t *testing.T) {
+		b, err := json.Marshal(in, opts, json.Deterministic(true))
+		if err != nil {
+			t.Fatalf("error marshaling: %v", err)
+		}
+		want := `{"plugins":{"exclamation":2,` +
+			`"join":{"a":{"_type":"literal","_value":"b"},"b":{"_type":"exclamation","_value":1}},` +
+			`"literal":"a"}}`
+		if string(b) != want {
+			t.Errorf("got:\n%s\nwant:\n%s", b, want)
+		}
+	})
+
+	t.Run("duplicates", func(t *testing.T) {
+		dup := pluginConfig{Plugins: oneof.ByType[fmt.Stringer]{LiteralStringer("a"), LiteralStringer("b")}}
+		_, err := json.Marshal(dup, opts)
+		if !errors.As(err, &oneof.ErrDuplicateDiscriminatorValue{}) {
+			t.Errorf("got error %v, want ErrDuplicateDiscriminatorValue", err)
+		}
+
+		var out pluginConfig
+		err = json.Unmarshal([]byte(`{"plugins":{"literal":"a","literal":"b"}}`), &out, opts, jsontext.AllowDuplicateNames(true))
+		if !errors.As(err, &oneof.ErrDuplicateDiscriminatorValue{}) {
+			t.Errorf("got error %v, want ErrDuplicateDiscriminatorValue", err)
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		var out pluginConfig
+		err := json.Unmarshal([]byte(`{"plugins":{"cache":{}}}`), &out, opts)
+		if !errors.As(err, &oneof.ErrUnknownDiscriminatorValue{}) {
+			t.Errorf("got error %v, want ErrUnknownDiscriminatorValue", err)
+		}
+	})
+}
@@ -0,0 +1,396 @@
+package oneof
+
+import (
+	"encoding"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// The CloudEvents specification version written and accepted by [CloudEvents]
+const cloudEventsSpecVersion = "1.0"
+
+// cloudEventsAttributes are the names of the context attributes of a
+// CloudEvent, and of its data, which cannot be used as extensions.
+var cloudEventsAttributes = map[string]bool{
+	"specversion":     true,
+	"id":              true,
+	"source":          true,
+	"type":            true,
+	"subject":         true,
+	"time":            true,
+	"datacontenttype": true,
+	"dataschema":      true,
+	"data":            true,
+	"data_base64":     true,
+}
+
+// Event is a CloudEvent whose data is a oneof value of type T. See
+// [CloudEvents].
+type Event[T any] struct {
+	// ID and Source are required, and together identify the event.
+	ID     string
+	Source string
+
+	// Type is the CloudEvents type of the event. When marshaling an
+	// event with data, it is set from the discriminator value of Data.
+	// Events without data must set Type.
+	Type string
+
+	Subject         string
+	Time            time.Time // omitted if zero
+	DataContentType string
+	DataSchema      string
+
+	// Extensions holds extension context attributes, by name.
+	Extensions map[string]any
+
+	// Data is nil for events without data.
+	Data T
+}
+
+// CloudEvents encodes and decodes [Event]s in the CloudEvents JSON format,
+// mapping the discriminator values of a [Registry] to CloudEvents types:
+//
+//	ce, err := oneof.NewCloudEvents(r, func(key string) string {
+//	  return "com.acme.orders." + key
+//	}, r.JSONOptions(nil))
+//
+//	b, err := ce.Marshal(oneof.Event[OrderEvent]{
+//	  ID:     "1",
+//	  Source: "/orders",
+//	  Data:   OrderCreated{ID: "o-1"},
+//	})
+//
+// produces JSON like:
+//
+//	{
+//	  "specversion": "1.0",
+//	  "id": "1",
+//	  "source": "/orders",
+//	  "type": "com.acme.orders.created",
+//	  "data": {"id": "o-1"}
+//	}
+//
+// Data is the default encoding of the option, without a wrapper. If the
+// DataContentType of an event is not a JSON media type (e.g.,
+// "application/pdf"), and its option implements [encoding.BinaryMarshaler],
+// data is instead encoded in "data_base64". A "data_base64" payload is decoded
+// with [encoding.BinaryUnmarshaler], or as JSON if the option does not
+// implement it and the data content type is JSON.
+//
+// The type of an event without data must still be the CloudEvents type of an
+// option, but its Data is left nil.
+type CloudEvents[T any] struct {
+	r      *Registry[T]
+	types  typeIndex
+	byKey  map[string]string // discriminator value -> CloudEvents type
+	byType map[string]string // CloudEvents type -> discriminator value
+	opts   json.Options
+}
+
+// NewCloudEvents freezes r and creates [CloudEvents] which map each of its
+// discriminator values to the CloudEvents type returned by eventType. If
+// eventType is nil, discriminator values are used as CloudEvents types.
+//
+// opts are used to encode and decode data. To encode and decode oneof values
+// nested within data, include, e.g., [Registry.JSONOptions].
+//
+// NewCloudEvents returns an error if eventType returns an empty type, or the
+// same type for several discriminator values.
+func NewCloudEvents[T any](r *Registry[T], eventType func(key string) string, opts ...json.Options) (*CloudEvents[T], error) {
+	r.Freeze()
+
+	if eventType == nil {
+		eventType = func(key string) string { return key }
+	}
+	c := &CloudEvents[T]{
+		r:      r,
+		types:  r.typeIndex(false),
+		byKey:  map[string]string{},
+		byType: map[string]string{},
+		opts:   json.JoinOptions(opts...),
+	}
+	for _, key := range r.Keys() {
+		typ := eventType(key)
+		if typ == "" {
+			return nil, fmt.Errorf("empty CloudEvents type for key %s", key)
+		}
+		if existing, ok := c.byType[typ]; ok {
+			return nil, fmt.Errorf("duplicate CloudEvents type %s for key %s (already used for key %s)", typ, key, existing)
+		}
+		c.byKey[key] = typ
+		c.byType[typ] = key
+	}
+	return c, nil
+}
+
+// JSONOptions returns [json.Options] which encode and decode [Event]s of
+// type T, including, e.g., batches of events ([]Event[T]). They include the
+// options passed to [NewCloudEvents].
+func (c *CloudEvents[T]) JSONOptions() json.Options {
+	// Marshalers replace, rather than join, any previous
+	// marshalers, so combine them with those in c.opts
+	marshalers := []*json.Marshalers{json.MarshalFuncV2(c.marshalEvent)}
+	if m, ok := json.GetOption(c.opts, json.WithMarshalers); ok && m != nil {
+		marshalers = append(marshalers, m)
+	}
+	unmarshalers := []*json.Unmarshalers{json.UnmarshalFuncV2(c.unmarshalEvent)}
+	if u, ok := json.GetOption(c.opts, json.WithUnmarshalers); ok && u != nil {
+		unmarshalers = append(unmarshalers, u)
+	}
+	return json.JoinOptions(
+		c.opts,
+		json.WithMarshalers(json.NewMarshalers(marshalers...)),
+		json.WithUnmarshalers(json.NewUnmarshalers(unmarshalers...)),
+	)
+}
+
+// Marshal encodes e.
+func (c *CloudEvents[T]) Marshal(e Event[T]) ([]byte, error) {
+	return json.Marshal(e, c.JSONOptions())
+}
+
+// Unmarshal decodes an event from b. If its type is not the CloudEvents type
+// of any option, Unmarshal returns [ErrUnknownDiscriminatorValue].
+func (c *CloudEvents[T]) Unmarshal(b []byte) (Event[T], error) {
+	var e Event[T]
+	err := json.Unmarshal(b, &e, c.JSONOptions())
+	return e, err
+}
+
+func (c *CloudEvents[T]) marshalEvent(enc *jsontext.Encoder, e Event[T], jsonopts json.Options) error {
+	if e.ID == "" {
+		return fmt.Errorf("missing CloudEvents id")
+	}
+	if e.Source == "" {
+		return fmt.Errorf("missing CloudEvents source")
+	}
+
+	// Find the type, and encode the data
+	var data jsonMember
+	if any(e.Data) != nil {
+		key, ok := c.types.keyFor(e.Data)
+		if !ok {
+			return ErrUnknownGoType{typ: fmt.Sprintf("%T", e.Data)}
+		}
+		e.Type = c.byKey[key]
+
+		// Binary data is only written in data_base64 if
+		// the content type says it is not JSON
+		if m, ok := any(e.Data).(encoding.BinaryMarshaler); ok && !isJSONMediaType(e.DataContentType) {
+			b, err := m.MarshalBinary()
+			if err != nil {
+				return fmt.Errorf("failed to marshal data: %w", err)
+			}
+			data = jsonMember{name: "data_base64", value: jsontext.Value(`"` + base64.StdEncoding.EncodeToString(b) + `"`)}
+		} else {
+			err := marshalDefault(e.Data, jsonopts, func(v jsontext.Value) error {
+				data = jsonMember{name: "data", value: v.Clone()}
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("failed to marshal data: %w", err)
+			}
+		}
+	}
+	if e.Type == "" {
+		return fmt.Errorf("missing CloudEvents type")
+	}
+
+	var root jsonObject
+	add := func(name, v string) error {
+		if v == "" {
+			return nil
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", name, err)
+		}
+		root = append(root, jsonMember{name: name, value: jsontext.Value(b)})
+		return nil
+	}
+	var timestamp string
+	if !e.Time.IsZero() {
+		timestamp = e.Time.Format(time.RFC3339Nano)
+	}
+	for _, a := range []struct{ name, value string }{
+		{"specversion", cloudEventsSpecVersion},
+		{"id", e.ID},
+		{"source", e.Source},
+		{"type", e.Type},
+		{"subject", e.Subject},
+		{"time", timestamp},
+		{"datacontenttype", e.DataContentType},
+		{"dataschema", e.DataSchema},
+	} {
+		if err := add(a.name, a.value); err != nil {
+			return err
+		}
+	}
+
+	// Write extensions in lexicographical order
+	names := make([]string, 0, len(e.Extensions))
+	for name := range e.Extensions {
+		if cloudEventsAttributes[name] {
+			return fmt.Errorf("extension %s conflicts with a CloudEvents attribute", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b, err := json.Marshal(e.Extensions[name], jsonopts)
+		if err != nil {
+			return fmt.Errorf("failed to encode extension %s: %w", name, err)
+		}
+		root = append(root, jsonMember{name: name, value: jsontext.Value(b)})
+	}
+
+	if data.name != "" {
+		root = append(root, data)
+	}
+	return root.encode(enc)
+}
+
+func (c *CloudEvents[T]) unmarshalEvent(dec *jsontext.Decoder, e *Event[T], jsonopts json.Options) error {
+	if k := dec.PeekKind(); k != '{' {
+		return fmt.Errorf("expected object start, but encountered %v", k)
+	}
+
+	var root jsonObject
+	if err := root.decode(dec); err != nil {
+		return err
+	}
+
+	// Decode the context attributes...
+	var ev Event[T]
+	var specVersion, timestamp string
+	for _, a := range []struct {
+		name     string
+		dst      *string
+		required bool
+	}{
+		{"specversion", &specVersion, true},
+		{"id", &ev.ID, true},
+		{"source", &ev.Source, true},
+		{"type", &ev.Type, true},
+		{"subject", &ev.Subject, false},
+		{"time", &timestamp, false},
+		{"datacontenttype", &ev.DataContentType, false},
+		{"dataschema", &ev.DataSchema, false},
+	} {
+		v, ok := root.take([]string{a.name})
+		if !ok {
+			if a.required {
+				return fmt.Errorf("missing CloudEvents %s", a.name)
+			}
+			continue
+		}
+		jv, ok := v.(jsontext.Value)
+		if !ok || jv.Kind() != '"' {
+			return fmt.Errorf("CloudEvents %s must be a string", a.name)
+		}
+		if err := json.Unmarshal(jv, a.dst); err != nil {
+			return fmt.Errorf("failed to decode CloudEvents %s: %w", a.name, err)
+		}
+	}
+	if specVersion != cloudEventsSpecVersion {
+		return fmt.Errorf("unsupported CloudEvents specversion %q", specVersion)
+	}
+	if timestamp != "" {
+		t, err := time.Parse(time.RFC3339Nano, timestamp)
+		if err != nil {
+			return fmt.Errorf("failed to decode CloudEvents time: %w", err)
+		}
+		ev.Time = t
+	}
+
+	key, ok := c.byType[ev.Type]
+	if !ok {
+		return ErrUnknownDiscriminatorValue{v: ev.Type}
+	}
+
+	// ...then the data...
+	data, hasData := root.take([]string{"data"})
+	data64, hasData64 := root.take([]string{"data_base64"})
+	switch {
+	case hasData && hasData64:
+		return fmt.Errorf("found both data and data_base64")
+	case hasData64:
+		if err := c.decodeBase64(&ev, key, data64, jsonopts); err != nil {
+			return err
+		}
+	case hasData && !isJSONNull(data):
+		if err := c.r.decodeOption(&ev.Data, key, encodeJSONTree(data), nil, jsonopts); err != nil {
+			return err
+		}
+	}
+
+	// ...and keep the remaining members as extensions
+	for _, m := range root {
+		if ev.Extensions == nil {
+			ev.Extensions = map[string]any{}
+		}
+		var v any
+		if err := json.Unmarshal(encodeJSONTree(m.value), &v, jsonopts); err != nil {
+			return fmt.Errorf("failed to decode extension %s: %w", m.name, err)
+		}
+		ev.Extensions[m.name] = v
+	}
+
+	*e = ev
+	return nil
+}
+
+// decodeBase64 decodes the data_base64 member v into a new value of the
+// option with discriminator key, and stores it in e.
+func (c *CloudEvents[T]) decodeBase64(e *Event[T], key string, v any, jsonopts json.Options) error {
+	jv, ok := v.(jsontext.Value)
+	if !ok || jv.Kind() != '"' {
+		return fmt.Errorf("CloudEvents data_base64 must be a string")
+	}
+	var s string
+	if err := json.Unmarshal(jv, &s); err != nil {
+		return fmt.Errorf("failed to decode data_base64: %w", err)
+	}
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return fmt.Errorf("failed to decode data_base64: %w", err)
+	}
+
+	opt, _ := c.r.lookupKey(key)
+	dst, err := opt.newValue()
+	if err != nil {
+		return err
+	}
+	if u, ok := dst.target.Interface().(encoding.BinaryUnmarshaler); ok {
+		if err := u.UnmarshalBinary(b); err != nil {
+			return fmt.Errorf("failed to unmarshal data to option type %v: %w", opt.typ, err)
+		}
+		e.Data = dst.result.Interface().(T)
+		return nil
+	}
+	if !isJSONMediaType(e.DataContentType) {
+		return fmt.Errorf("cannot decode %s data into option type %v", e.DataContentType, opt.typ)
+	}
+	return c.r.decodeOption(&e.Data, key, jsontext.Value(b), nil, jsonopts)
+}
+
+// isJSONMediaType reports whether the media type mt is a JSON media type (an
+// empty media type means JSON, in the CloudEvents JSON format).
+func isJSONMediaType(mt string) bool {
+	if mt == "" {
+		return true
+	}
+	parsed, _, err := mime.ParseMediaType(mt)
+	if err != nil {
+		return false
+	}
+	return parsed == "application/json" || parsed == "text/json" || strings.HasSuffix(parsed, "+json")
+}
@@ -0,0 +1,204 @@
+package oneof_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dhoelle/oneof"
+	"github.com/go-json-experiment/json"
+)
+
+type orderEvent interface{ isOrderEvent() }
+
+type orderCreated struct {
+	ID    string       `json:"id"`
+	Items []orderEvent `json:"items,omitempty"`
+}
+
+type orderCancelled struct{}
+
+// orderInvoice is a binary payload
+type orderInvoice []byte
+
+func (b orderInvoice) MarshalBinary() ([]byte, error) { return b, nil }
+func (b *orderInvoice) UnmarshalBinary(data []byte) error {
+	*b = append((*b)[:0], data...)
+	return nil
+}
+
+// orderDue implements encoding.BinaryMarshaler (through time.Time), but is
+// JSON data
+type orderDue struct{ time.Time }
+
+func (orderCreated) isOrderEvent()   {}
+func (orderCancelled) isOrderEvent() {}
+func (orderInvoice) isOrderEvent()   {}
+func (orderDue) isOrderEvent()       {}
+
+func Test_CloudEvents(t *testing.T) {
+	r := oneof.NewRegistry[orderEvent]().
+		MustRegister("created", orderCreated{}).
+		MustRegister("cancelled", orderCancelled{}).
+		MustRegister("invoice", orderInvoice(nil)).
+		MustRegister("due", orderDue{})
+	ce, err := oneof.NewCloudEvents(r, func(key string) string {
+		return "com.acme.orders." + key
+	}, r.JSONOptions(nil))
+	if err != nil {
+		t.Fatalf("error creating codec: %v", err)
+	}
+
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("round trip", func(t *testing.T) {
+		in := oneof.Event[orderEvent]{
+			ID:              "1",
+			Source:          "/orders",
+			Subject:         "o-1",
+			Time:            ts,
+			DataContentType: "application/json",
+			Extensions:      map[string]any{"traceparent": "00-abc-01", "partition": 3.0},
+			Data:            orderCreated{ID: "o-1", Items: []orderEvent{orderCancelled{}}},
+		}
+		b, err := ce.Marshal(in)
+		if err != nil {
+			t.Fatalf("error marshaling: %v", err)
+		}
+		want := `{"specversion":"1.0","id":"1","source":"/orders","type":"com.acme.orders.created",` +
+			`"subject":"o-1","time":"2024-05-01T12:00:00Z","datacontenttype":"application/json",` +
+			`"partition":3,"traceparent":"00-abc-01",` +
+			`"data":{"id":"o-1","items":[{"_type":"cancelled"}]}}`
+		if string(b) != want {
+			t.Errorf("got:\n%s\nwant:\n%s", b, want)
+		}
+
+		out, err := ce.Unmarshal(b)
+		if err != nil {
+			t.Fatalf("error unmarshaling: %v", err)
+		}
+		in.Type = "com.acme.orders.created"
+		if !reflect.DeepEqual(out, in) {
+			t.Errorf("got %#v, want %#v", out, in)
+		}
+	})
+
+	t.Run("data_base64", func(t *testing.T) {
+		in := oneof.Event[orderEvent]{
+			ID:              "2",
+			Source:          "/orders",
+			DataContentType: "application/pdf",
+			Data:            orderInvoice("%PDF"),
+		}
+		b, err := ce.Marshal(in)
+		if err != nil {
+			t.Fatalf("error marshaling: %v", err)
+		}
+		want := `{"specversion":"1.0","id":"2","source":"/orders","type":"com.acme.orders.invoice",` +
+			`"datacontenttype":"application/pdf","data_base64":"JVBERg=="}`
+		if string(b) != want {
+			t.Errorf("got:\n%s\nwant:\n%s", b, want)
+		}
+
+		out, err := ce.Unmarshal(b)
+		if err != nil {
+			t.Fatalf("error unmarshaling: %v", err)
+		}
+		if !reflect.DeepEqual(out.Data, in.Data) {
+			t.Errorf("got %#v, want %#v", out.Data, in.Data)
+		}
+	})
+
+	t.Run("binary marshaler with JSON data", func(t *testing.T) {
+		in := oneof.Event[orderEvent]{
+			ID:     "6",
+			Source: "/orders",
+			Data:   orderDue{ts},
+		}
+		b, err := ce.Marshal(in)
+		if err != nil {
+			t.Fatalf("error marshaling: %v", err)
+		}
+		want := `{"specversion":"1.0","id":"6","source":"/orders","type":"com.acme.orders.due",` +
+			`"data":"2024-05-01T12:00:00Z"}`
+		if string(b) != want {
+			t.Errorf("got:\n%s\nwant:\n%s", b, want)
+		}
+
+		out, err := ce.Unmarshal(b)
+		if err != nil {
+			t.Fatalf("error unmarshaling: %v", err)
+		}
+		if !reflect.DeepEqual(out.Data, in.Data) {
+			t.Errorf("got %#v, want %#v", out.Data, in.Data)
+		}
+	})
+
+	t.Run("base64 JSON data", func(t *testing.T) {
+		in := `{"specversion":"1.0","id":"3","source":"/orders","type":"com.acme.orders.created",` +
+			`"data_base64":"eyJpZCI6Im8tMyJ9"}`
+		out, err := ce.Unmarshal([]byte(in))
+		if err != nil {
+			t.Fatalf("error unmarshaling: %v", err)
+		}
+		if want := (orderCreated{ID: "o-3"}); !reflect.DeepEqual(out.Data, want) {
+			t.Errorf("got %#v, want %#v", out.Data, want)
+		}
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		in := `[` +
+			`{"specversion":"1.0","id":"4","source":"/orders","type":"com.acme.orders.cancelled"},` +
+			`{"specversion":"1.0","id":"5","source":"/orders","type":"com.acme.orders.created","data":{"id":"o-5"}}]`
+		var out []oneof.Event[orderEvent]
+		if err := json.Unmarshal([]byte(in), &out, ce.JSONOptions()); err != nil {
+			t.Fatalf("error unmarshaling: %v", err)
+		}
+		// The first event has no data
+		want := []oneof.Event[orderEvent]{
+			{ID: "4", Source: "/orders", Type: "com.acme.orders.cancelled"},
+			{ID: "5", Source: "/orders", Type: "com.acme.orders.created", Data: orderCreated{ID: "o-5"}},
+		}
+		if !reflect.DeepEqual(out, want) {
+			t.Errorf("got %#v, want %#v", out, want)
+		}
+
+		b, err := json.Marshal(out, ce.JSONOptions())
+		if err != nil {
+			t.Fatalf("error marshaling: %v", err)
+		}
+		if string(b) != in {
+			t.Errorf("got:\n%s\nwant:\n%s", b, in)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for _, in := range []string{
+			`{"specversion":"1.0","source":"/orders","type":"com.acme.orders.created"}`,
+			`{"specversion":"0.3","id":"1","source":"/orders","type":"com.acme.orders.created"}`,
+			`{"specversion":"1.0","id":"1","source":"/orders","type":"com.acme.orders.created","data":{},"data_base64":""}`,
+			`{"specversion":"1.0","id":"1","source":"/orders","type":"com.acme.orders.created","datacontenttype":"text/plain","data_base64":"aGk="}`,
+		} {
+			if _, err := ce.Unmarshal([]byte(in)); err == nil {
+				t.Errorf("%s: expected an error", in)
+			}
+		}
+
+		_, err := ce.Unmarshal([]byte(`{"specversion":"1.0","id":"1","source":"/orders","type":"com.acme.orders.lost"}`))
+		var unknown oneof.ErrUnknownDiscriminatorValue
+		if !errors.As(err, &unknown) {
+			t.Errorf("got error %v, want ErrUnknownDiscriminatorValue", err)
+		}
+
+		_, err = ce.Marshal(oneof.Event[orderEvent]{
+			ID:         "1",
+			Source:     "/orders",
+			Extensions: map[string]any{"subject": "x"},
+			Data:       orderCancelled{},
+		})
+		if err == nil {
+			t.Errorf("expected an error for an extension named like an attribute")
+		}
+	})
+}
@@ -0,0 +1,153 @@
+package oneof
+
+import (
+	"fmt"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// The JSON object key for the values of a compact slice. See
+// [Config.CompactSlices].
+const defaultNestedValuesKey = "_values"
+
+// compactSliceMarshalFunc creates a [json.MarshalFuncV2] which encodes a []T
+// whose elements all share a discriminator as a single object, e.g.:
+//
+//	{"_type": "circle", "_values": [{"radius": 1}, {"radius": 2}]}
+//
+// Other slices keep their default encoding, in which each element is wrapped
+// individually. So do slices whose elements wrapFunc would wrap with a
+// discriminator in another place than the compact form.
+func (r *Registry[T]) compactSliceMarshalFunc(types typeIndex, wrapFunc func(typ string, v jsontext.Value) WrappedValue) *json.Marshalers {
+	return json.MarshalFuncV2(func(enc *jsontext.Encoder, s []T, jsonopts json.Options) error {
+		if len(s) == 0 {
+			return json.SkipFunc
+		}
+
+		var discriminatorValue string
+		for i, t := range s {
+			if any(t) == nil {
+				return json.SkipFunc
+			}
+			key, ok := types.keyFor(t)
+			if !ok || (i > 0 && key != discriminatorValue) {
+				return json.SkipFunc
+			}
+			discriminatorValue = key
+		}
+		if !hasDefaultDiscriminator(wrapFunc(discriminatorValue, nil), discriminatorValue) {
+			return json.SkipFunc
+		}
+
+		if err := enc.WriteToken(jsontext.ObjectStart); err != nil {
+			return fmt.Errorf("failed to write object start token: %w", err)
+		}
+		if err := enc.WriteToken(jsontext.String(defaultTypeDiscriminatorKey)); err != nil {
+			return fmt.Errorf("failed to write discriminator key token: %w", err)
+		}
+		if err := enc.WriteToken(jsontext.String(discriminatorValue)); err != nil {
+			return fmt.Errorf("failed to write discriminator value token %s: %w", discriminatorValue, err)
+		}
+		if err := enc.WriteToken(jsontext.String(defaultNestedValuesKey)); err != nil {
+			return fmt.Errorf("failed to write values key token: %w", err)
+		}
+		if err := enc.WriteToken(jsontext.ArrayStart); err != nil {
+			return fmt.Errorf("failed to write array start token: %w", err)
+		}
+		for _, t := range s {
+			err := marshalDefault(t, jsonopts, func(v jsontext.Value) error {
+				return enc.WriteValue(v)
+			})
+			if err != nil {
+				return fmt.Errorf("failed to marshal t: %w", err)
+			}
+		}
+		if err := enc.WriteToken(jsontext.ArrayEnd); err != nil {
+			return fmt.Errorf("failed to write array end token: %w", err)
+		}
+		if err := enc.WriteToken(jsontext.ObjectEnd); err != nil {
+			return fmt.Errorf("failed to write object end token: %w", err)
+		}
+		return nil
+	})
+}
+
+// hasDefaultDiscriminator reports whether w writes the discriminator value
+// typ as a string under the default "_type" key, like the compact form of
+// slices.
+func hasDefaultDiscriminator(w WrappedValue, typ string) bool {
+	var key string
+	switch w := w.(type) {
+	case alwaysNestWrappedValue, inlineObjectsWrappedValue:
+		key = defaultTypeDiscriminatorKey
+	case customKeyWrappedType:
+		key = w.discriminatorKey
+	default:
+		return false
+	}
+	return key == defaultTypeDiscriminatorKey && w.Type() == typ
+}
+
+// compactSliceUnmarshalFunc creates a [json.UnmarshalFuncV2] which decodes a
+// []T from the compact form written by [Registry.compactSliceMarshalFunc].
+// JSON arrays keep their default decoding, in which each element is unwrapped
+// individually.
+func (r *Registry[T]) compactSliceUnmarshalFunc() *json.Unmarshalers {
+	return json.UnmarshalFuncV2(func(dec *jsontext.Decoder, ptr *[]T, jsonopts json.Options) error {
+		if dec.PeekKind() != '{' {
+			return json.SkipFunc
+		}
+
+		var root jsonObject
+		if err := root.decode(dec); err != nil {
+			return err
+		}
+
+		var (
+			discriminatorValue string
+			values             []jsontext.Value
+			foundType          bool
+			foundValues        bool
+		)
+		for _, m := range root {
+			v, ok := m.value.(jsontext.Value)
+			switch {
+			case m.name == defaultTypeDiscriminatorKey && ok && v.Kind() == '"':
+				if err := json.Unmarshal(v, &discriminatorValue); err != nil {
+					return fmt.Errorf("failed to decode discriminator: %w", err)
+				}
+				foundType = true
+			case m.name == defaultTypeDiscriminatorKey:
+				return fmt.Errorf(`value for discriminator key "%s" must be a string`, defaultTypeDiscriminatorKey)
+			case m.name == defaultNestedValuesKey && ok && v.Kind() == '[':
+				if err := json.Unmarshal(v, &values); err != nil {
+					return fmt.Errorf("failed to decode values: %w", err)
+				}
+				foundValues = true
+			case m.name == defaultNestedValuesKey:
+				return fmt.Errorf(`value for key "%s" must be an array`, defaultNestedValuesKey)
+			default:
+				return fmt.Errorf(`unexpected key "%s"`, m.name)
+			}
+		}
+		if !foundType {
+			return fmt.Errorf(`missing discriminator "%s"`, defaultTypeDiscriminatorKey)
+		}
+		if !foundValues {
+			return fmt.Errorf(`missing values "%s"`, defaultNestedValuesKey)
+		}
+		if _, ok := r.lookupKey(discriminatorValue); !ok {
+			return ErrUnknownDiscriminatorValue{v: discriminatorValue}
+		}
+
+		s := make([]T, len(values))
+		for i, v := range values {
+			if err := r.decodeOption(&s[i], discriminatorValue, v, nil, jsonopts); err != nil {
+				return err
+			}
+		}
+		*ptr = s
+		return nil
+	})
+}
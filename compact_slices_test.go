@@ -0,0 +1,168 @@
+package oneof_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/dhoelle/oneof"
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+type compactShapes struct {
+	Shapes []fmt.Stringer `json:"shapes"`
+}
+
+func Test_CompactSlices(t *testing.T) {
+	r := oneof.NewRegistry[fmt.Stringer]().
+		MustRegister("literal", LiteralStringer("")).
+		MustRegister("join", JoinStringer{}).
+		MustRegister("exclamation", ExclamationPointsStringer(0))
+	opts := r.JSONOptions(&oneof.Config{CompactSlices: true})
+
+	tests := []struct {
+		name string
+		in   compactShapes
+		want string
+	}{
+		{
+			name: "homogeneous",
+			in:   compactShapes{Shapes: []fmt.Stringer{LiteralStringer("a"), LiteralStringer("b")}},
+			want: `{"shapes":{"_type":"literal","_values":["a","b"]}}`,
+		},
+		{
+			name: "nested",
+			in: compactShapes{Shapes: []fmt.Stringer{
+				JoinStringer{A: LiteralStringer("a"), B: ExclamationPointsStringer(1)},
+				JoinStringer{A: LiteralStringer("b"), B: LiteralStringer("c")},
+			}},
+			want: `{"shapes":{"_type":"join","_values":[` +
+				`{"a":{"_type":"literal","_value":"a"},"b":{"_type":"exclamation","_value":1}},` +
+				`{"a":{"_type":"literal","_value":"b"},"b":{"_type":"literal","_value":"c"}}]}}`,
+		},
+		{
+			name: "mixed",
+			in:   compactShapes{Shapes: []fmt.Stringer{LiteralStringer("a"), ExclamationPointsStringer(2)}},
+			want: `{"shapes":[{"_type":"literal","_value":"a"},{"_type":"exclamation","_value":2}]}`,
+		},
+		{
+			name: "empty",
+			in:   compactShapes{Shapes: []fmt.Stringer{}},
+			want: `{"shapes":[]}`,
+		},
+		{
+			name: "nil",
+			in:   compactShapes{},
+			want: `{"shapes":[]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := json.Marshal(tt.in, opts)
+			if err != nil {
+				t.Fatalf("error marshaling: %v", err)
+			}
+			if string(b) != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", b, tt.want)
+			}
+
+			var out compactShapes
+			if err := json.Unmarshal(b, &out, opts); err != nil {
+				t.Fatalf("error unmarshaling: %v", err)
+			}
+			want := tt.in
+			if want.Shapes == nil {
+				want.Shapes = []fmt.Stringer{}
+			}
+			if !reflect.DeepEqual(out, want) {
+				t.Errorf("got %#v, want %#v", out, want)
+			}
+		})
+	}
+
+	// Decoding accepts both forms, even without CompactSlices
+	defaultOpts := r.JSONOptions(nil)
+	var out compactShapes
+	if err := json.Unmarshal([]byte(`{"shapes":{"_type":"exclamation","_values":[1,2]}}`), &out, defaultOpts); err != nil {
+		t.Fatalf("error unmarshaling: %v", err)
+	}
+	if want := []fmt.Stringer{ExclamationPointsStringer(1), ExclamationPointsStringer(2)}; !reflect.DeepEqual(out.Shapes, want) {
+		t.Errorf("got %#v, want %#v", out.Shapes, want)
+	}
+
+	for _, in := range []string{
+		`{"shapes":{"_type":"square","_values":[1]}}`,
+		`{"shapes":{"_type":"exclamation"}}`,
+		`{"shapes":{"_values":[1]}}`,
+		`{"shapes":{"_type":"exclamation","_values":[1],"extra":true}}`,
+	} {
+		if err := json.Unmarshal([]byte(in), &out, defaultOpts); err == nil {
+			t.Errorf("unmarshaling %s: got nil error", in)
+		}
+	}
+}
+
+func Test_CompactSlicesWrapFunc(t *testing.T) {
+	r := oneof.NewRegistry[fmt.Stringer]().
+		MustRegister("literal", LiteralStringer(""))
+	in := compactShapes{Shapes: []fmt.Stringer{LiteralStringer("a"), LiteralStringer("b")}}
+
+	// Slices are compacted with WrapFuncs which write the
+	// discriminator under the default key, and otherwise
+	// wrap each element
+	tests := []struct {
+		name     string
+		wrapFunc func(string, jsontext.Value) oneof.WrappedValue
+		want     string
+	}{
+		{
+			name:     "inline",
+			wrapFunc: oneof.WrapInline,
+			want:     `{"shapes":{"_type":"literal","_values":["a","b"]}}`,
+		},
+		{
+			name:     "custom value wrapper",
+			wrapFunc: oneof.CustomValueWrapper{}.Wrap,
+			want:     `{"shapes":{"_type":"literal","_values":["a","b"]}}`,
+		},
+		{
+			name: "closure",
+			wrapFunc: func(typ string, v jsontext.Value) oneof.WrappedValue {
+				return oneof.WrapNested(typ, v)
+			},
+			want: `{"shapes":{"_type":"literal","_values":["a","b"]}}`,
+		},
+		{
+			name:     "custom key",
+			wrapFunc: oneof.CustomValueWrapper{DiscriminatorKey: "kind"}.Wrap,
+			want:     `{"shapes":[{"kind":"literal","_value":"a"},{"kind":"literal","_value":"b"}]}`,
+		},
+		{
+			name:     "tuple",
+			wrapFunc: oneof.WrapTuple,
+			want:     `{"shapes":[["literal","a"],["literal","b"]]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := r.JSONOptions(&oneof.Config{WrapFunc: tt.wrapFunc, CompactSlices: true})
+			b, err := json.Marshal(in, opts)
+			if err != nil {
+				t.Fatalf("error marshaling: %v", err)
+			}
+			if string(b) != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", b, tt.want)
+			}
+
+			var out compactShapes
+			if err := json.Unmarshal(b, &out, opts); err != nil {
+				t.Fatalf("error unmarshaling: %v", err)
+			}
+			if !reflect.DeepEqual(out, in) {
+				t.Errorf("got %#v, want %#v", out, in)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package oneof
+
+import (
+	"fmt"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// CompositeKey returns the discriminator value of an option which is
+// identified by several JSON values, such as the apiVersion and kind of a
+// Kubernetes object:
+//
+//	r.MustRegister(oneof.CompositeKey("apps/v1", "Deployment"), &Deployment{})
+//
+// The result is the JSON array of values, e.g., ["apps/v1","Deployment"].
+// See [CustomValueWrapper] (DiscriminatorPaths).
+func CompositeKey(values ...string) string {
+	// A []string always marshals, once invalid UTF-8 is
+	// allowed (and replaced)
+	b, _ := json.Marshal(values, jsontext.AllowInvalidUTF8(true))
+	return string(b)
+}
+
+// splitCompositeKey returns the n values of the composite key, as created by
+// [CompositeKey].
+func splitCompositeKey(key string, n int) ([]string, error) {
+	var values []string
+	if err := json.Unmarshal([]byte(key), &values); err != nil {
+		return nil, fmt.Errorf("discriminator %s is not a composite key: %w", key, err)
+	}
+	if len(values) != n {
+		return nil, fmt.Errorf("composite discriminator %s has %d values, want %d", key, len(values), n)
+	}
+	return values, nil
+}
@@ -0,0 +1,89 @@
+package oneof_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/dhoelle/oneof"
+	"github.com/go-json-experiment/json"
+)
+
+type k8sMetadata struct {
+	Name string `json:"name"`
+}
+
+type k8sDeployment struct {
+	Metadata k8sMetadata `json:"metadata"`
+	Replicas int         `json:"replicas"`
+}
+
+func (d *k8sDeployment) String() string { return "deployment/" + d.Metadata.Name }
+
+type k8sDeploymentV1Beta1 struct {
+	Metadata k8sMetadata `json:"metadata"`
+}
+
+func (d *k8sDeploymentV1Beta1) String() string { return "deployment/" + d.Metadata.Name }
+
+type k8sService struct {
+	Metadata k8sMetadata `json:"metadata"`
+}
+
+func (s *k8sService) String() string { return "service/" + s.Metadata.Name }
+
+func Test_CompositeDiscriminator(t *testing.T) {
+	r := oneof.NewRegistry[fmt.Stringer]().
+		MustRegister(oneof.CompositeKey("apps/v1", "Deployment"), &k8sDeployment{}).
+		MustRegister(oneof.CompositeKey("apps/v1beta1", "Deployment"), &k8sDeploymentV1Beta1{}).
+		MustRegister(oneof.CompositeKey("v1", "Service"), &k8sService{})
+	cw := oneof.CustomValueWrapper{
+		DiscriminatorPaths: []string{"/apiVersion", "/kind"},
+		InlineObjects:      true,
+	}
+	opts := r.JSONOptions(&oneof.Config{WrapFunc: cw.Wrap})
+
+	in := []fmt.Stringer{
+		&k8sDeployment{Metadata: k8sMetadata{Name: "web"}, Replicas: 3},
+		&k8sDeploymentV1Beta1{Metadata: k8sMetadata{Name: "old"}},
+		&k8sService{Metadata: k8sMetadata{Name: "web"}},
+	}
+	b, err := json.Marshal(in, opts)
+	if err != nil {
+		t.Fatalf("error marshaling: %v", err)
+	}
+	want := `[{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"web"},"replicas":3},` +
+		`{"apiVersion":"apps/v1beta1","kind":"Deployment","metadata":{"name":"old"}},` +
+		`{"apiVersion":"v1","kind":"Service","metadata":{"name":"web"}}]`
+	if string(b) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b, want)
+	}
+
+	var out []fmt.Stringer
+	if err := json.Unmarshal(b, &out, opts); err != nil {
+		t.Fatalf("error unmarshaling: %v", err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("got %#v, want %#v", out, in)
+	}
+
+	// The keys may appear anywhere in the object
+	var s fmt.Stringer
+	if err := json.Unmarshal([]byte(`{"metadata":{"name":"db"},"kind":"Service","apiVersion":"v1"}`), &s, opts); err != nil {
+		t.Fatalf("error unmarshaling: %v", err)
+	}
+	if want := (&k8sService{Metadata: k8sMetadata{Name: "db"}}); !reflect.DeepEqual(s, want) {
+		t.Errorf("got %#v, want %#v", s, want)
+	}
+
+	// Each part of the discriminator is required
+	if err := json.Unmarshal([]byte(`{"kind":"Service","metadata":{"name":"db"}}`), &s, opts); err == nil {
+		t.Errorf("got nil error, want error")
+	}
+
+	// Options must be registered under a composite key
+	bad := oneof.NewRegistry[fmt.Stringer]().MustRegister("Service", &k8sService{})
+	if _, err := json.Marshal(in[2], bad.JSONOptions(&oneof.Config{WrapFunc: cw.Wrap})); err == nil {
+		t.Errorf("got nil error, want error")
+	}
+}
@@ -0,0 +1,506 @@
+package oneof
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// SiblingKeeper is implemented by option types which keep the JSON data that
+// a [CustomValueWrapper] with a DiscriminatorPath or ValuePath finds outside of
+// those paths, so that the data is written back when the value is marshaled.
+//
+// For example, with DiscriminatorPath "/metadata/type" and ValuePath "/spec",
+// the siblings of
+//
+//	{"metadata": {"type": "circle", "name": "c1"}, "spec": {"radius": 1}}
+//
+// are {"metadata": {"name": "c1"}}.
+//
+// SetOneofSiblings is called on a pointer to the decoded value. OneofSiblings
+// is called on the value being marshaled, so it should have a value receiver
+// for non-pointer options.
+type SiblingKeeper interface {
+	OneofSiblings() jsontext.Value
+	SetOneofSiblings(jsontext.Value)
+}
+
+// siblingWrappedValue is implemented by [WrappedValue]s which carry JSON data
+// found alongside the discriminator and value. See [SiblingKeeper].
+type siblingWrappedValue interface {
+	WrappedValue
+	siblings() jsontext.Value
+	withSiblings(jsontext.Value) WrappedValue
+}
+
+// customPathWrappedType is the [WrappedValue] created by a
+// [CustomValueWrapper] with a DiscriminatorPath, DiscriminatorPaths or
+// ValuePath.
+type customPathWrappedType struct {
+	// discriminatorPaths are the paths of the discriminator. If
+	// there are several, the discriminator is composite (see
+	// [CompositeKey]).
+	discriminatorPaths [][]string
+
+	// valuePath is the path of the value. If nil, object values
+	// are inlined at the root, and other values are nested under
+	// nestedValueKey.
+	valuePath      []string
+	nestedValueKey string
+
+	rejectSiblings bool
+
+	// If jsonDiscriminator is true, the discriminator may be any
+	// JSON string, number or boolean, and discriminatorValue holds
+	// its canonical JSON text (see [JSONKey]).
+	jsonDiscriminator bool
+
+	discriminatorValue string
+	discriminatorToken jsontext.Value // as decoded, if jsonDiscriminator is set
+	value              jsontext.Value
+	siblingValue       jsontext.Value
+}
+
+func (w customPathWrappedType) Type() string             { return w.discriminatorValue }
+func (w customPathWrappedType) Value() jsontext.Value    { return w.value }
+func (w customPathWrappedType) siblings() jsontext.Value { return w.siblingValue }
+func (w customPathWrappedType) token() jsontext.Value    { return w.discriminatorToken }
+func (w customPathWrappedType) discriminatorMember() string {
+	return w.discriminatorPaths[0][0]
+}
+func (w customPathWrappedType) withSiblings(v jsontext.Value) WrappedValue {
+	w.siblingValue = v
+	return w
+}
+
+func (w customPathWrappedType) MarshalJSONV2(enc *jsontext.Encoder, opts json.Options) error {
+	values, err := w.encodeDiscriminator()
+	if err != nil {
+		return err
+	}
+
+	var root jsonObject
+	if len(w.siblingValue) > 0 {
+		if err := root.parse(w.siblingValue); err != nil {
+			return fmt.Errorf("failed to parse siblings: %w", err)
+		}
+	}
+
+	for i, p := range w.discriminatorPaths {
+		if err := root.set(p, values[i]); err != nil {
+			return err
+		}
+	}
+
+	var value jsonObject
+	switch {
+	case len(w.value) == 0:
+		// Don't write a value
+	case w.valuePath == nil && w.value.Kind() == '{':
+		// Inline the members of the value
+		if err := value.parse(w.value); err != nil {
+			return fmt.Errorf("failed to parse value: %w", err)
+		}
+	case w.valuePath == nil:
+		if err := value.set([]string{w.nestedValueKey}, w.value); err != nil {
+			return err
+		}
+	default:
+		if err := value.set(w.valuePath, w.value); err != nil {
+			return err
+		}
+	}
+	for _, p := range w.discriminatorPaths {
+		if _, ok := value.get(p); ok {
+			return fmt.Errorf("value already contains discriminator %s", formatJSONPointer(p))
+		}
+	}
+	if err := root.merge(value); err != nil {
+		return err
+	}
+
+	return root.encode(enc)
+}
+
+func (w *customPathWrappedType) UnmarshalJSONV2(dec *jsontext.Decoder, opts json.Options) error {
+	if k := dec.PeekKind(); k != '{' {
+		return fmt.Errorf("expected object start, but encountered %v", k)
+	}
+
+	var root jsonObject
+	if err := root.decode(dec); err != nil {
+		return err
+	}
+
+	values := make([]jsontext.Value, len(w.discriminatorPaths))
+	for i, p := range w.discriminatorPaths {
+		dv, ok := root.take(p)
+		if !ok {
+			return fmt.Errorf(`missing discriminator "%s"`, formatJSONPointer(p))
+		}
+		jv, ok := dv.(jsontext.Value)
+		switch {
+		case ok && jv.Kind() == '"':
+		case ok && w.jsonDiscriminator && (jv.Kind() == '0' || jv.Kind() == 't' || jv.Kind() == 'f'):
+		case w.jsonDiscriminator:
+			return fmt.Errorf(`value for discriminator "%s" must be a string, number or boolean`, formatJSONPointer(p))
+		default:
+			return fmt.Errorf(`value for discriminator "%s" must be a string`, formatJSONPointer(p))
+		}
+		values[i] = jv
+	}
+	if err := w.decodeDiscriminator(values); err != nil {
+		return err
+	}
+
+	if w.valuePath == nil {
+		// Everything but the discriminator is the value
+		switch {
+		case len(root) == 1 && root[0].name == w.nestedValueKey:
+			w.value = encodeJSONTree(root[0].value)
+		case len(root) > 0:
+			w.value = encodeJSONTree(root)
+		}
+		return nil
+	}
+
+	if v, ok := root.take(w.valuePath); ok {
+		w.value = encodeJSONTree(v)
+	}
+
+	if len(root) > 0 {
+		if w.rejectSiblings {
+			return fmt.Errorf("unexpected data outside of the discriminator and value: %s", encodeJSONTree(root))
+		}
+		w.siblingValue = encodeJSONTree(root)
+	}
+	return nil
+}
+
+// encodeDiscriminator returns the JSON values to write at each of the
+// discriminator paths.
+func (w customPathWrappedType) encodeDiscriminator() ([]jsontext.Value, error) {
+	n := len(w.discriminatorPaths)
+
+	if w.jsonDiscriminator {
+		values := []jsontext.Value{jsontext.Value(w.discriminatorValue)}
+		if n > 1 {
+			values = nil
+			if err := json.Unmarshal([]byte(w.discriminatorValue), &values); err != nil || len(values) != n {
+				return nil, fmt.Errorf("discriminator %s is not a composite key with %d values", w.discriminatorValue, n)
+			}
+		}
+		for _, v := range values {
+			if k := v.Kind(); !v.IsValid() || (k != '"' && k != '0' && k != 't' && k != 'f') {
+				return nil, fmt.Errorf("discriminator %s is not a JSON string, number or boolean", v)
+			}
+		}
+		return values, nil
+	}
+
+	strs := []string{w.discriminatorValue}
+	if n > 1 {
+		var err error
+		if strs, err = splitCompositeKey(w.discriminatorValue, n); err != nil {
+			return nil, err
+		}
+	}
+	values := make([]jsontext.Value, len(strs))
+	for i, s := range strs {
+		v, err := json.Marshal(s)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode discriminator value %s: %w", s, err)
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// decodeDiscriminator sets the discriminator from the JSON values found at
+// each of the discriminator paths.
+func (w *customPathWrappedType) decodeDiscriminator(values []jsontext.Value) error {
+	if w.jsonDiscriminator {
+		canonical := make([]jsontext.Value, len(values))
+		for i, v := range values {
+			canonical[i] = v.Clone()
+			if err := canonical[i].Canonicalize(); err != nil {
+				return fmt.Errorf("failed to canonicalize discriminator %s: %w", v, err)
+			}
+		}
+		if len(values) == 1 {
+			w.discriminatorValue = string(canonical[0])
+			w.discriminatorToken = values[0]
+		} else {
+			w.discriminatorValue = string(joinJSONArray(canonical))
+			w.discriminatorToken = joinJSONArray(values)
+		}
+		return nil
+	}
+
+	strs := make([]string, len(values))
+	for i, v := range values {
+		if err := json.Unmarshal(v, &strs[i]); err != nil {
+			return fmt.Errorf("failed to decode discriminator: %w", err)
+		}
+	}
+	w.discriminatorValue = strs[0]
+	if len(strs) > 1 {
+		w.discriminatorValue = CompositeKey(strs...)
+	}
+	return nil
+}
+
+// joinJSONArray returns the JSON array of values.
+func joinJSONArray(values []jsontext.Value) jsontext.Value {
+	b := []byte{'['}
+	for i, v := range values {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = append(b, v...)
+	}
+	return append(b, ']')
+}
+
+// parseJSONPointer splits a JSON Pointer (RFC 6901), such as "/metadata/type",
+// into its reference tokens. The empty pointer "" refers to the whole
+// document, and is returned as an empty, non-nil slice.
+func parseJSONPointer(p string) ([]string, error) {
+	if p == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(p, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q: must be empty or start with '/'", p)
+	}
+	tokens := strings.Split(p[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(t)
+	}
+	return tokens, nil
+}
+
+// formatJSONPointer is the inverse of [parseJSONPointer].
+func formatJSONPointer(tokens []string) string {
+	var sb strings.Builder
+	for _, t := range tokens {
+		sb.WriteByte('/')
+		sb.WriteString(strings.NewReplacer("~", "~0", "/", "~1").Replace(t))
+	}
+	return sb.String()
+}
+
+// jsonObject is a JSON object whose members keep their order. Member values
+// are either nested jsonObjects or (non-object) jsontext.Values.
+type jsonObject []jsonMember
+
+type jsonMember struct {
+	name  string
+	value any // jsonObject or jsontext.Value
+}
+
+// parse decodes the JSON object v into o.
+func (o *jsonObject) parse(v jsontext.Value) error {
+	if v.Kind() != '{' {
+		return fmt.Errorf("expected a JSON object, got %s", v)
+	}
+	dec := jsontext.NewDecoder(bytes.NewReader(v))
+	return o.decode(dec)
+}
+
+// decode reads the next JSON object from dec into o.
+func (o *jsonObject) decode(dec *jsontext.Decoder) error {
+	if _, err := dec.ReadToken(); err != nil {
+		return fmt.Errorf("failed to read object start token: %w", err)
+	}
+	for dec.PeekKind() != '}' {
+		tok, err := dec.ReadToken()
+		if err != nil {
+			return fmt.Errorf("failed to read object key token: %w", err)
+		}
+		m := jsonMember{name: tok.String()}
+		if dec.PeekKind() == '{' {
+			var child jsonObject
+			if err := child.decode(dec); err != nil {
+				return err
+			}
+			m.value = child
+		} else {
+			v, err := dec.ReadValue()
+			if err != nil {
+				return fmt.Errorf("failed to read value of %s: %w", m.name, err)
+			}
+			m.value = v.Clone()
+		}
+		*o = append(*o, m)
+	}
+	if _, err := dec.ReadToken(); err != nil {
+		return fmt.Errorf("failed to read object end token: %w", err)
+	}
+	return nil
+}
+
+// encode writes o to enc.
+func (o jsonObject) encode(enc *jsontext.Encoder) error {
+	if err := enc.WriteToken(jsontext.ObjectStart); err != nil {
+		return fmt.Errorf("failed to write object start token: %w", err)
+	}
+	for _, m := range o {
+		if err := enc.WriteToken(jsontext.String(m.name)); err != nil {
+			return fmt.Errorf("failed to write key token %s: %w", m.name, err)
+		}
+		switch v := m.value.(type) {
+		case jsonObject:
+			if err := v.encode(enc); err != nil {
+				return err
+			}
+		case jsontext.Value:
+			if err := enc.WriteValue(v); err != nil {
+				return fmt.Errorf("failed to write value of %s: %w", m.name, err)
+			}
+		}
+	}
+	if err := enc.WriteToken(jsontext.ObjectEnd); err != nil {
+		return fmt.Errorf("failed to write object end token: %w", err)
+	}
+	return nil
+}
+
+// encodeJSONTree encodes a jsonObject or jsontext.Value as a jsontext.Value.
+func encodeJSONTree(v any) jsontext.Value {
+	o, ok := v.(jsonObject)
+	if !ok {
+		return v.(jsontext.Value)
+	}
+	var buf bytes.Buffer
+	enc := jsontext.NewEncoder(&buf)
+	if err := o.encode(enc); err != nil {
+		// o was decoded from valid JSON, so it always
+		// encodes successfully
+		panic(err)
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
+
+func (o jsonObject) index(name string) int {
+	for i, m := range o {
+		if m.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// get returns the value at path.
+func (o jsonObject) get(path []string) (any, bool) {
+	if len(path) == 0 {
+		return o, true
+	}
+	i := o.index(path[0])
+	if i < 0 {
+		return nil, false
+	}
+	if len(path) == 1 {
+		return o[i].value, true
+	}
+	child, ok := o[i].value.(jsonObject)
+	if !ok {
+		return nil, false
+	}
+	return child.get(path[1:])
+}
+
+// set sets the value at path, creating objects along the path as needed.
+func (o *jsonObject) set(path []string, v any) error {
+	if len(path) == 0 {
+		return fmt.Errorf("cannot set the root of a JSON object")
+	}
+	if jv, ok := v.(jsontext.Value); ok && jv.Kind() == '{' {
+		var child jsonObject
+		if err := child.parse(jv); err != nil {
+			return err
+		}
+		v = child
+	}
+
+	i := o.index(path[0])
+	if len(path) == 1 {
+		if i < 0 {
+			*o = append(*o, jsonMember{name: path[0], value: v})
+		} else {
+			(*o)[i].value = v
+		}
+		return nil
+	}
+
+	if i < 0 {
+		*o = append(*o, jsonMember{name: path[0], value: jsonObject{}})
+		i = len(*o) - 1
+	}
+	child, ok := (*o)[i].value.(jsonObject)
+	if !ok {
+		return fmt.Errorf("cannot set %s: %s is not an object", formatJSONPointer(path), path[0])
+	}
+	if err := child.set(path[1:], v); err != nil {
+		return err
+	}
+	(*o)[i].value = child
+	return nil
+}
+
+// merge sets the members of src in o, merging objects which are present in
+// both.
+func (o *jsonObject) merge(src jsonObject) error {
+	for _, m := range src {
+		i := o.index(m.name)
+		if i < 0 {
+			*o = append(*o, m)
+			continue
+		}
+		dst, ok := (*o)[i].value.(jsonObject)
+		srcObj, srcOK := m.value.(jsonObject)
+		if !ok || !srcOK {
+			return fmt.Errorf("conflicting values for %s", m.name)
+		}
+		if err := dst.merge(srcObj); err != nil {
+			return err
+		}
+		(*o)[i].value = dst
+	}
+	return nil
+}
+
+// take removes and returns the value at path. Objects along the path which
+// are left empty are removed too.
+func (o *jsonObject) take(path []string) (any, bool) {
+	if len(path) == 0 {
+		v := *o
+		*o = nil
+		return v, true
+	}
+	i := o.index(path[0])
+	if i < 0 {
+		return nil, false
+	}
+	if len(path) == 1 {
+		v := (*o)[i].value
+		*o = append((*o)[:i], (*o)[i+1:]...)
+		return v, true
+	}
+	child, ok := (*o)[i].value.(jsonObject)
+	if !ok {
+		return nil, false
+	}
+	v, ok := child.take(path[1:])
+	if !ok {
+		return nil, false
+	}
+	if len(child) == 0 {
+		*o = append((*o)[:i], (*o)[i+1:]...)
+	} else {
+		(*o)[i].value = child
+	}
+	return v, true
+}
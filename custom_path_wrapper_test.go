@@ -0,0 +1,178 @@
+package oneof_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/dhoelle/oneof"
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// pathCircle keeps the JSON data found outside of its discriminator and
+// value paths
+type pathCircle struct {
+	Radius   float64 `json:"radius"`
+	siblings jsontext.Value
+}
+
+func (c *pathCircle) String() string                       { return "circle" }
+func (c *pathCircle) OneofSiblings() jsontext.Value        { return c.siblings }
+func (c *pathCircle) SetOneofSiblings(data jsontext.Value) { c.siblings = data }
+
+type pathSquare struct {
+	Side float64 `json:"side"`
+}
+
+func (pathSquare) String() string { return "square" }
+
+func Test_CustomValueWrapperPaths(t *testing.T) {
+	r := oneof.NewRegistry[fmt.Stringer]().
+		MustRegister("circle", &pathCircle{}).
+		MustRegister("square", pathSquare{}).
+		MustRegister("exclamation", ExclamationPointsStringer(0))
+
+	tests := []struct {
+		name    string
+		cw      oneof.CustomValueWrapper
+		in      string
+		want    fmt.Stringer
+		out     string // if empty, same as in
+		wantErr bool
+	}{
+		{
+			name: "nested paths",
+			cw:   oneof.CustomValueWrapper{DiscriminatorPath: "/metadata/type", ValuePath: "/spec"},
+			in:   `{"metadata":{"type":"square"},"spec":{"side":2}}`,
+			want: pathSquare{Side: 2},
+		},
+		{
+			name: "non-object value",
+			cw:   oneof.CustomValueWrapper{DiscriminatorPath: "/metadata/type", ValuePath: "/spec/count"},
+			in:   `{"metadata":{"type":"exclamation"},"spec":{"count":3}}`,
+			want: ExclamationPointsStringer(3),
+		},
+		{
+			name: "escaped paths",
+			cw:   oneof.CustomValueWrapper{DiscriminatorPath: "/a~1b/~0t", ValuePath: "/v"},
+			in:   `{"a/b":{"~t":"square"},"v":{"side":2}}`,
+			want: pathSquare{Side: 2},
+		},
+		{
+			name: "inline objects at the root",
+			cw:   oneof.CustomValueWrapper{DiscriminatorPath: "/metadata/type", InlineObjects: true},
+			in:   `{"metadata":{"type":"square"},"side":2}`,
+			want: pathSquare{Side: 2},
+		},
+		{
+			name: "inline non-objects",
+			cw:   oneof.CustomValueWrapper{DiscriminatorPath: "/metadata/type", InlineObjects: true},
+			in:   `{"metadata":{"type":"exclamation"},"_value":3}`,
+			want: ExclamationPointsStringer(3),
+		},
+		{
+			name: "siblings are preserved",
+			cw:   oneof.CustomValueWrapper{DiscriminatorPath: "/metadata/type", ValuePath: "/spec"},
+			in:   `{"metadata":{"name":"c1","type":"circle"},"spec":{"radius":1},"status":{"ok":true}}`,
+			want: &pathCircle{
+				Radius:   1,
+				siblings: jsontext.Value(`{"metadata":{"name":"c1"},"status":{"ok":true}}`),
+			},
+			out: `{"metadata":{"name":"c1","type":"circle"},"status":{"ok":true},"spec":{"radius":1}}`,
+		},
+		{
+			name: "siblings are dropped for other types",
+			cw:   oneof.CustomValueWrapper{DiscriminatorPath: "/metadata/type", ValuePath: "/spec"},
+			in:   `{"metadata":{"name":"s1","type":"square"},"spec":{"side":2}}`,
+			want: pathSquare{Side: 2},
+			out:  `{"metadata":{"type":"square"},"spec":{"side":2}}`,
+		},
+		{
+			name:    "siblings are rejected",
+			cw:      oneof.CustomValueWrapper{DiscriminatorPath: "/metadata/type", ValuePath: "/spec", RejectSiblings: true},
+			in:      `{"metadata":{"name":"s1","type":"square"},"spec":{"side":2}}`,
+			wantErr: true,
+		},
+		{
+			name:    "missing discriminator",
+			cw:      oneof.CustomValueWrapper{DiscriminatorPath: "/metadata/type", ValuePath: "/spec"},
+			in:      `{"metadata":{},"spec":{"side":2}}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid path",
+			cw:      oneof.CustomValueWrapper{DiscriminatorPath: "metadata/type"},
+			in:      `{"metadata":{"type":"square"}}`,
+			wantErr: true,
+		},
+		{
+			name:    "empty path",
+			cw:      oneof.CustomValueWrapper{DiscriminatorPaths: []string{""}},
+			in:      `{"square":{}}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := r.JSONOptions(&oneof.Config{WrapFunc: tt.cw.Wrap})
+
+			var got fmt.Stringer
+			err := json.Unmarshal([]byte(tt.in), &got, opts)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got nil error, want error (value: %#v)", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error unmarshaling: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+
+			b, err := json.Marshal(&got, opts)
+			if err != nil {
+				t.Fatalf("error marshaling: %v", err)
+			}
+			out := tt.out
+			if out == "" {
+				out = tt.in
+			}
+			if string(b) != out {
+				t.Errorf("got JSON:\n%s\nwant:\n%s", b, out)
+			}
+		})
+	}
+}
+
+func Test_CustomValueWrapperDiscriminatorOnly(t *testing.T) {
+	r := oneof.NewRegistry[fmt.Stringer]().
+		MustRegister("square", pathSquare{})
+	cw := oneof.CustomValueWrapper{DiscriminatorKey: "$type"}
+
+	var got fmt.Stringer
+	if err := json.Unmarshal([]byte(`{"$type":"square"}`), &got, r.JSONOptions(&oneof.Config{WrapFunc: cw.Wrap})); err != nil {
+		t.Fatalf("error unmarshaling: %v", err)
+	}
+	if want := (pathSquare{}); got != want {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
+
+func Test_CustomValueWrapperEmptyPathAny(t *testing.T) {
+	r := oneof.NewRegistry[any]().
+		MustRegister("square", pathSquare{})
+	cw := oneof.CustomValueWrapper{DiscriminatorPaths: []string{""}}
+	opts := r.JSONOptions(&oneof.Config{WrapFunc: cw.Wrap})
+
+	var got any
+	if err := json.Unmarshal([]byte(`{"a":1}`), &got, opts); err == nil {
+		t.Errorf("got nil error unmarshaling, want error (value: %#v)", got)
+	}
+	if _, err := json.Marshal(map[string]any{"a": pathSquare{}}, opts); err == nil {
+		t.Errorf("got nil error marshaling, want error")
+	}
+}
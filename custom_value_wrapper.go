@@ -22,9 +22,48 @@ type CustomValueWrapper struct {
 	DiscriminatorKey string
 	NestedValueKey   string
 	InlineObjects    bool
+
+	// DiscriminatorPath, if non-empty, is a JSON Pointer (RFC 6901) to the
+	// discriminator, such as "/metadata/type". It replaces
+	// DiscriminatorKey.
+	//
+	// Objects along the path are created when encoding. Paths may only
+	// traverse JSON objects (not arrays).
+	DiscriminatorPath string
+
+	// DiscriminatorPaths, if non-empty, are JSON Pointers to the parts of
+	// a composite discriminator, such as "/apiVersion" and "/kind". It
+	// replaces DiscriminatorKey and DiscriminatorPath.
+	//
+	// With several paths, options are registered under a [CompositeKey]
+	// of the values found at those paths, in order.
+	DiscriminatorPaths []string
+
+	// ValuePath, if non-empty, is a JSON Pointer (RFC 6901) to the encoded
+	// Go value, such as "/spec". It replaces NestedValueKey and
+	// InlineObjects.
+	ValuePath string
+
+	// If DiscriminatorPath, DiscriminatorPaths or ValuePath is set, JSON
+	// data outside of the discriminator and value is kept, and handed to
+	// decoded values which implement [SiblingKeeper] (and dropped for
+	// other values).
+	//
+	// If RejectSiblings is true, such data is instead an error.
+	RejectSiblings bool
+
+	// If JSONDiscriminator is true, the discriminator may be any JSON
+	// string, number or boolean, e.g., {"t": 17, "d": {...}}. Options are
+	// registered under the JSON text of their discriminator, as returned
+	// by [JSONKey] (or use a [KeyedRegistry]).
+	JSONDiscriminator bool
 }
 
 func (b CustomValueWrapper) Empty() WrappedValue {
+	if b.usesPaths() {
+		return b.pathWrapper("", nil)
+	}
+
 	discriminatorKey := defaultTypeDiscriminatorKey
 	if b.DiscriminatorKey != "" {
 		discriminatorKey = b.DiscriminatorKey
@@ -42,6 +81,10 @@ func (b CustomValueWrapper) Empty() WrappedValue {
 }
 
 func (b CustomValueWrapper) Wrap(typ string, v jsontext.Value) WrappedValue {
+	if b.usesPaths() {
+		return b.pathWrapper(typ, v)
+	}
+
 	discriminatorKey := defaultTypeDiscriminatorKey
 	if b.DiscriminatorKey != "" {
 		discriminatorKey = b.DiscriminatorKey
@@ -67,6 +110,94 @@ func (b CustomValueWrapper) Wrap(typ string, v jsontext.Value) WrappedValue {
 	return t
 }
 
+// usesPaths reports whether b locates the discriminator or value by JSON
+// Pointer paths (which also support JSON discriminators).
+func (b CustomValueWrapper) usesPaths() bool {
+	return b.DiscriminatorPath != "" || len(b.DiscriminatorPaths) > 0 || b.ValuePath != "" || b.JSONDiscriminator
+}
+
+// pathWrapper creates the [WrappedValue] for a CustomValueWrapper with a
+// DiscriminatorPath, DiscriminatorPaths or ValuePath.
+func (b CustomValueWrapper) pathWrapper(typ string, v jsontext.Value) WrappedValue {
+	discriminatorKey := defaultTypeDiscriminatorKey
+	if b.DiscriminatorKey != "" {
+		discriminatorKey = b.DiscriminatorKey
+	}
+
+	nestedValueKey := defaultNestedValueKey
+	if b.NestedValueKey != "" {
+		nestedValueKey = b.NestedValueKey
+	}
+
+	w := customPathWrappedType{
+		discriminatorPaths: [][]string{{discriminatorKey}},
+		nestedValueKey:     nestedValueKey,
+		rejectSiblings:     b.RejectSiblings,
+		jsonDiscriminator:  b.JSONDiscriminator,
+		discriminatorValue: typ,
+		value:              v,
+	}
+
+	switch {
+	case b.DiscriminatorPath != "" && len(b.DiscriminatorPaths) > 0:
+		err := fmt.Errorf("only one of DiscriminatorPath and DiscriminatorPaths may be set")
+		return invalidWrappedValue{typ: typ, value: v, err: err}
+	case b.DiscriminatorPath != "":
+		p, err := parseJSONPointer(b.DiscriminatorPath)
+		if err != nil {
+			return invalidWrappedValue{typ: typ, value: v, err: err}
+		}
+		w.discriminatorPaths = [][]string{p}
+	case len(b.DiscriminatorPaths) > 0:
+		w.discriminatorPaths = make([][]string, len(b.DiscriminatorPaths))
+		for i, s := range b.DiscriminatorPaths {
+			p, err := parseJSONPointer(s)
+			if err != nil {
+				return invalidWrappedValue{typ: typ, value: v, err: err}
+			}
+			w.discriminatorPaths[i] = p
+		}
+	}
+	for _, p := range w.discriminatorPaths {
+		if len(p) == 0 {
+			err := fmt.Errorf("custom discriminator path is empty")
+			return invalidWrappedValue{typ: typ, value: v, err: err}
+		}
+	}
+
+	switch {
+	case b.ValuePath != "":
+		p, err := parseJSONPointer(b.ValuePath)
+		if err != nil {
+			return invalidWrappedValue{typ: typ, value: v, err: err}
+		}
+		w.valuePath = p
+	case !b.InlineObjects:
+		w.valuePath = []string{nestedValueKey}
+	}
+
+	return w
+}
+
+// invalidWrappedValue is a [WrappedValue] which fails to marshal and
+// unmarshal with err, for misconfigured wrappers.
+type invalidWrappedValue struct {
+	typ   string
+	value jsontext.Value
+	err   error
+}
+
+func (w invalidWrappedValue) Type() string          { return w.typ }
+func (w invalidWrappedValue) Value() jsontext.Value { return w.value }
+
+func (w invalidWrappedValue) MarshalJSONV2(enc *jsontext.Encoder, opts json.Options) error {
+	return w.err
+}
+
+func (w *invalidWrappedValue) UnmarshalJSONV2(dec *jsontext.Decoder, opts json.Options) error {
+	return w.err
+}
+
 type customKeyWrappedType struct {
 	discriminatorKey   string
 	discriminatorValue string
@@ -75,7 +206,8 @@ type customKeyWrappedType struct {
 	inlineValue        jsontext.Value
 }
 
-func (w customKeyWrappedType) Type() string { return w.discriminatorValue }
+func (w customKeyWrappedType) Type() string                { return w.discriminatorValue }
+func (w customKeyWrappedType) discriminatorMember() string { return w.discriminatorKey }
 func (w customKeyWrappedType) Value() jsontext.Value {
 	if len(w.inlineValue) > 0 {
 		return w.inlineValue
@@ -191,6 +323,8 @@ func (w *customKeyWrappedType) UnmarshalJSONV2(dec *jsontext.Decoder, opts json.
 		return fmt.Errorf(`value for discriminator key "%s" must be a string (got %T)`, w.discriminatorKey, dv)
 	}
 
+	w.discriminatorValue = dvs
+
 	// Remove the discriminator from the map
 	delete(m, w.discriminatorKey)
 
@@ -240,7 +374,6 @@ func (w *customKeyWrappedType) UnmarshalJSONV2(dec *jsontext.Decoder, opts json.
 	} else {
 		w.inlineValue = v
 	}
-	w.discriminatorValue = dvs
 
 	return nil
 }
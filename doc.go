@@ -103,12 +103,101 @@
 //	  // other url.URL fields omitted
 //	}
 //
+// For interoperability with other languages, [oneof] also defines wrappers
+// for other common union layouts:
+//
+//   - [WrapExternal] (externally tagged): {"url.URL": {"Host": "example.com"}}
+//   - [AdjacentWrapper] (adjacently tagged): {"t": "url.URL", "c": {"Host": "example.com"}}
+//   - [WrapTuple]: ["url.URL", {"Host": "example.com"}]
+//
 // For finer-grained control, you can create your own implementation of
 // [WrappedValue], or use [CustomValueWrapper] ([CustomValueWrapper.Wrap] can be
 // used as the WrapFunc in [Config])
 //
+// [CustomValueWrapper] can also find the discriminator and value at nested
+// JSON Pointer paths, e.g., with DiscriminatorPath "/metadata/type" and
+// ValuePath "/spec":
+//
+//	{
+//	  "metadata": {"type": "url.URL"},
+//	  "spec": {"Host": "example.com"}
+//	}
+//
+// JSON data outside of those paths is handed to decoded values which implement
+// [SiblingKeeper] (and written back when they are marshaled), or, if
+// RejectSiblings is set, is an error.
+//
+// To identify options by several JSON values, such as the apiVersion and kind
+// of Kubernetes objects, set DiscriminatorPaths and register options under a
+// [CompositeKey]:
+//
+//	r.MustRegister(oneof.CompositeKey("apps/v1", "Deployment"), &Deployment{})
+//	cw := oneof.CustomValueWrapper{
+//	  DiscriminatorPaths: []string{"/apiVersion", "/kind"},
+//	  InlineObjects:      true,
+//	}
+//
 // See the [Config] and [CustomValueWrapper] examples for more details.
 //
+// # Non-string discriminators
+//
+// To identify options by JSON numbers or booleans, such as numeric message
+// type IDs, set the JSONDiscriminator field of [CustomValueWrapper], and
+// register options in a [KeyedRegistry], keyed by a comparable Go type:
+//
+//	r := oneof.NewKeyedRegistry[int, Message]().
+//	  MustRegister(17, &Heartbeat{})
+//	cw := oneof.CustomValueWrapper{
+//	  DiscriminatorKey:  "t",
+//	  NestedValueKey:    "d",
+//	  JSONDiscriminator: true,
+//	}
+//
+// encodes {"t": 17, "d": {...}}. Discriminators are compared by their
+// canonical JSON form, so 17, 17.0 and 1.7e1 are the same discriminator.
+// [ErrUnknownDiscriminatorValue.Token] reports unknown discriminators as they
+// appeared in the JSON input.
+//
+// # Compact slices
+//
+// Set the CompactSlices field of [Config] to encode slices of T whose
+// elements all share a discriminator as a single object, rather than by
+// wrapping each element:
+//
+//	{"_type": "circle", "_values": [{"radius": 1}, {"radius": 2}]}
+//
+// Slices whose elements have different discriminators are encoded as usual.
+// [UnmarshalFunc] decodes slices of T from either form. The compact form uses
+// the default "_type" key, so with a WrapFunc which writes the discriminator
+// elsewhere, slices are also encoded as usual.
+//
+// # Collections keyed by discriminator
+//
+// A [ByType] collection holds at most one value of each option, and encodes as
+// a JSON object keyed by discriminator value:
+//
+//	type Config struct {
+//	  Plugins oneof.ByType[Plugin] `json:"plugins"`
+//	}
+//
+// encodes like {"plugins": {"auth": {...}, "cache": {...}}}. Members are
+// written in the order of the collection, unless [json.Deterministic] is set.
+//
+// # Discriminators in a parent object
+//
+// Some formats store the discriminator of a oneof value in a sibling member of
+// its parent object (like Jackson's EXTERNAL_PROPERTY):
+//
+//	{
+//	  "kind": "s3",
+//	  "config": {"bucket": "x"}
+//	}
+//
+// Use [SiblingDiscriminator] to create options for the parent struct type,
+// naming the JSON members of the value and the discriminator:
+//
+//	opts, err := oneof.SiblingDiscriminator[Bucket](r, "config", "kind", nil)
+//
 // # Handling missing keys
 //
 // If [oneof] encounters a Go type for which there is no matching option key
@@ -135,5 +224,189 @@
 // ReplaceMissingTypeFunc. If you need to marshal and unmarshal a Go type,
 // include it in the option set.
 //
+// # Registries
+//
+// A [Registry] is an alternative to a map of options. Options are registered
+// in a fixed order, and registration rejects duplicate keys and duplicate Go
+// types, so every Go type always encodes with the same discriminator:
+//
+//	r := oneof.NewRegistry[fmt.Stringer]()
+//	if err := r.Register("crypto.Hash", crypto.Hash(0)); err != nil {
+//	  return err
+//	}
+//	opts := r.JSONOptions(nil) // freezes r
+//
+// [Register] adds an option by its Go type, without a prototype value, and
+// checks that the type implements T:
+//
+//	oneof.MustRegister[fmt.Stringer, *url.URL](r, "url.URL")
+//
+// [Registry.RegisterFunc] adds an option whose values are created by a
+// factory function, for types that must be initialized by a constructor.
+// [UnmarshalFunc] calls the factory for each decoded value, then decodes the
+// remaining JSON into the result:
+//
+//	r.MustRegisterFunc("cache", func() Store { return NewCache() })
+//
+// [MarshalFunc], [UnmarshalFunc] and [JSONOptions] adapt their map of options
+// to a [Registry], registering keys in lexicographical order.
+//
+// # Multiple interfaces
+//
+// If a Go type implements more than one interface that oneof handles, use
+// [JoinRegistries] to combine their registries. The combined options find a
+// value's discriminator in any of the registries, wrap each value exactly
+// once, and report an error if registries disagree about the discriminator
+// for a Go type:
+//
+//	opts, err := oneof.JoinRegistries(nil, sources, sinks)
+//
+// # Intercepting only values of type T
+//
+// [github.com/go-json-experiment/json] applies a marshal func for an interface
+// type T to every Go value that implements T. For example, with T =
+// fmt.Stringer, [MarshalFunc] would also intercept time.Duration and net.IP
+// fields, and fail to marshal them if they are not in the option set.
+//
+// Set the StaticTypeOnly field of [Config] to only wrap values whose static
+// type is exactly T (struct fields, slice elements and map values declared as
+// T), and leave concretely typed values with their default encoding:
+//
+//	cfg := &oneof.Config{
+//	  StaticTypeOnly: true,
+//	}
+//
+// # Options for any
+//
+// T may be any (the empty interface). Since every Go value implements any,
+// oneof then only intercepts values whose static type is any (as if
+// StaticTypeOnly were set), such as the values of a map[string]any:
+//
+//   - Values of registered types are wrapped as usual
+//   - Values of other types, including JSON primitives, keep their default
+//     encoding (unless ReplaceMissingTypeFunc is set)
+//   - When unmarshaling, only wrapped JSON values with a registered
+//     discriminator are decoded using the options; all other JSON values
+//     decode by default
+//
+// # Pointer and non-pointer options
+//
+// By default, options match Go values ignoring one level of pointer
+// indirection, so crypto.Hash and *crypto.Hash share a discriminator. Set the
+// StrictTypes field of [Config] to match options by their exact Go type, so
+// that, e.g., Config and *Config can be registered as distinct options, and
+// each decodes to the same form that was encoded.
+//
+// # Valueless options
+//
+// Options whose default encoding is an empty JSON object, such as
+// struct{}-based marker types, can be encoded as a bare JSON string holding
+// their discriminator by setting the ValuelessAsString field of [Config]:
+//
+//	["round_robin", {"_type": "weighted", "_value": {"weights": [1, 2]}}]
+//
+// With ValuelessAsString set, [UnmarshalFunc] accepts a bare JSON string as
+// the discriminator of an option with its zero value.
+//
+// # Shorthand forms
+//
+// An option may have a compact, scalar form, such as "redis://localhost" for
+// {"_type": "redis", "_value": {"url": "redis://localhost"}}. Register a
+// [Shorthand] with [Registry.RegisterShorthand] to parse JSON strings or
+// numbers into a value of T while unmarshaling, and, optionally, to write the
+// shorthand form while marshaling, when it would not lose information:
+//
+//	r.MustRegisterShorthand("redis", oneof.Shorthand[Backend]{
+//	  Parse:  parseRedisURL,
+//	  Format: formatRedisURL,
+//	})
+//
+// # HTTP media types
+//
+// [MediaTypes] identify options by the Content-Type header of HTTP requests
+// and responses, such as application/vnd.acme.circle+json, rather than by a
+// discriminator in the body. Bodies hold the default encoding of the option:
+//
+//	mt, err := oneof.NewMediaTypes(r, oneof.VendorMediaType("acme"), r.JSONOptions(nil))
+//	shape, err := mt.DecodeRequest(req)
+//	err = mt.Write(w, http.StatusOK, shape)
+//
+// Unknown media types are reported as [ErrUnknownDiscriminatorValue], e.g., to
+// respond with 415 Unsupported Media Type. Bodies larger than the MaxBodySize
+// field of [MediaTypes] (10 MiB by default) are rejected.
+//
+// # Schema URLs
+//
+// A [SchemaWrapper] identifies options by a schema URL, such as
+// {"$schema": "https://acme.dev/schemas/storage/s3/v2.json", "bucket": "x"}.
+// Each discriminator value is registered with URL patterns, which may contain
+// a {version} placeholder, and a canonical version which is written when
+// marshaling:
+//
+//	sw := oneof.NewSchemaWrapper().
+//	  MustRegister("s3", "https://acme.dev/schemas/storage/s3/v{version}.json", "2")
+//	opts := r.JSONOptions(&oneof.Config{WrapFunc: sw.Wrap})
+//
+// The version found when unmarshaling is handed to decoded values which
+// implement [SchemaVersionKeeper], and to SchemaWrapper.OnDecode. Like a
+// [Registry], a SchemaWrapper is frozen by its first use.
+//
+// # Conventions of other ecosystems
+//
+// The package defines WrapFuncs which reproduce the polymorphic JSON
+// conventions of other ecosystems, which store the discriminator in a property
+// of a JSON object:
+//
+//   - [WrapJacksonClass] and [WrapJacksonType]: Jackson's "@class" and "@type"
+//     properties; values which are not JSON objects are written as
+//     ["type", value] arrays
+//   - [PydanticWrapper]: Pydantic's discriminated unions; the discriminator is
+//     also a field of the value
+//   - [WrapGraphQL]: GraphQL's "__typename"; the discriminator is also a field
+//     of the value
+//   - [WrapJSONLD]: JSON-LD's "@type", which may be an array of types
+//   - [WrapMongo]: the "_t" discriminator of MongoDB drivers, which may be an
+//     array holding a class hierarchy
+//
+// # google.protobuf.Any
+//
+// [ProtoAnyWrapper] follows the JSON mapping of google.protobuf.Any, as
+// produced by protojson and gRPC-gateway, for options registered under the
+// full names of protobuf messages:
+//
+//	{"@type": "type.googleapis.com/acme.storage.v1.Bucket", "bucket": "x"}
+//
+// Values of well-known types with a special JSON mapping, such as
+// google.protobuf.Duration, are nested under "value". When unmarshaling, only
+// the last path segment of the type URL is resolved against the registry.
+//
+// # CloudEvents
+//
+// [CloudEvents] encodes and decodes [Event]s in the CloudEvents JSON format,
+// mapping discriminator values to CloudEvents types, and decoding the "data"
+// of an event into T:
+//
+//	ce, err := oneof.NewCloudEvents(r, func(key string) string {
+//	  return "com.acme.orders." + key
+//	}, r.JSONOptions(nil))
+//	e, err := ce.Unmarshal(b)
+//
+// Events carry the standard context attributes and extensions. If the data
+// content type of an event is not JSON, options which implement
+// [encoding.BinaryMarshaler] are encoded in "data_base64". Events without data
+// decode with a nil Data.
+//
+// # Query strings and environment variables
+//
+// [FlatCodec] encodes and decodes values as flat key-value pairs, such as
+// ?filter.type=range&filter.min=3, using [url.Values], or, with [EnvCodec],
+// environment variables such as STORE__TYPE=s3 STORE__BUCKET=x, in the form of
+// [os.Environ]. Use FlatCodec.Wrap as the WrapFunc of registries, so that the
+// fields of options (including nested oneof values) are inlined next to their
+// discriminators:
+//
+//	opts := r.JSONOptions(&oneof.Config{WrapFunc: oneof.EnvCodec.Wrap})
+//	err := oneof.EnvCodec.DecodeEnv(os.Environ(), "STORE", &store, opts)
+//
 // [github.com/go-json-experiment/json]: https://github.com/go-json-experiment/json
 package oneof
@@ -1,6 +1,11 @@
 package oneof
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
 
 // ErrUnknownGoType is the error returned by MarshalFunc when it encounters a Go
 // type that is not in the provided set of options
@@ -16,9 +21,80 @@ func (e ErrUnknownGoType) Error() string {
 // encounters a JSON discriminator value which is not in the provided set of
 // options
 type ErrUnknownDiscriminatorValue struct {
-	v string
+	v     string
+	token jsontext.Value // the discriminator as it appeared in the JSON, if known
 }
 
 func (e ErrUnknownDiscriminatorValue) Error() string {
+	if len(e.token) > 0 {
+		return fmt.Sprintf("unknown discriminator value %s", e.token)
+	}
 	return fmt.Sprintf("unknown discriminator value %s", e.v)
 }
+
+// Token returns the unknown discriminator as it appeared in the JSON input,
+// e.g., "circle" (including quotes) or 17.
+func (e ErrUnknownDiscriminatorValue) Token() jsontext.Value {
+	if len(e.token) > 0 {
+		return e.token
+	}
+	b, err := json.Marshal(e.v)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// ErrDuplicateKey is the error returned by [Registry.Register] when an option
+// is already registered under the same key
+type ErrDuplicateKey struct {
+	key string
+}
+
+func (e ErrDuplicateKey) Error() string {
+	return fmt.Sprintf("duplicate key %s", e.key)
+}
+
+// ErrDuplicateGoType is the error returned by [Registry.Register] when an
+// option of the same Go type is already registered under another key
+type ErrDuplicateGoType struct {
+	typ         string
+	key         string
+	existingKey string
+}
+
+func (e ErrDuplicateGoType) Error() string {
+	return fmt.Sprintf("duplicate Go type %s for key %s (already registered for key %s)", e.typ, e.key, e.existingKey)
+}
+
+// ErrRegistryFrozen is the error returned by [Registry.Register] (and
+// [SchemaWrapper.Register]) after the registry has been frozen
+type ErrRegistryFrozen struct {
+	key string
+}
+
+func (e ErrRegistryFrozen) Error() string {
+	return fmt.Sprintf("cannot register key %s: registry is frozen", e.key)
+}
+
+// ErrConflictingDiscriminators is the error returned by [JoinRegistries] when
+// the same Go type is registered under different discriminator values
+type ErrConflictingDiscriminators struct {
+	typ         string
+	key         string
+	existingKey string
+}
+
+func (e ErrConflictingDiscriminators) Error() string {
+	return fmt.Sprintf("conflicting discriminator values %s and %s for Go type %s", e.existingKey, e.key, e.typ)
+}
+
+// ErrDuplicateDiscriminatorValue is the error returned when marshaling a
+// [ByType] collection with more than one element of the same option
+type ErrDuplicateDiscriminatorValue struct {
+	v string
+}
+
+func (e ErrDuplicateDiscriminatorValue) Error() string {
+	return fmt.Sprintf("duplicate discriminator value %s", e.v)
+}
@@ -0,0 +1,40 @@
+package oneof_test
+
+import (
+	"crypto"
+	"fmt"
+	"net/url"
+
+	"github.com/dhoelle/oneof"
+	"github.com/go-json-experiment/json"
+)
+
+func ExampleRegistry() {
+	// Register implementations of fmt.Stringer, in order.
+	// Registering the same key or Go type twice is an error.
+	r := oneof.NewRegistry[fmt.Stringer]()
+	if err := r.Register("crypto.Hash", crypto.Hash(0)); err != nil {
+		panic(err)
+	}
+	if err := r.Register("url.URL", &url.URL{}); err != nil {
+		panic(err)
+	}
+
+	// Using the registry freezes it
+	opts := r.JSONOptions(nil)
+
+	var s1 fmt.Stringer = crypto.SHA256
+	b, _ := json.Marshal(s1, opts)
+	fmt.Println(string(b))
+
+	var s2 fmt.Stringer
+	_ = json.Unmarshal(b, &s2, opts)
+	fmt.Printf("unmarshaled type = %T\n", s2)
+
+	err := r.Register("literal", LiteralStringer(""))
+	fmt.Println(err)
+	// Output:
+	// {"_type":"crypto.Hash","_value":5}
+	// unmarshaled type = crypto.Hash
+	// cannot register key literal: registry is frozen
+}
@@ -0,0 +1,426 @@
+package oneof
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+const (
+	// The default discriminator key of a [FlatCodec]
+	defaultFlatDiscriminatorKey = "type"
+
+	// The default nested value key of a [FlatCodec]
+	defaultFlatNestedValueKey = "value"
+)
+
+// FlatCodec encodes and decodes values as flat key-value pairs, such as the
+// parameters of a query string:
+//
+//	?filter.type=range&filter.min=3
+//
+// or environment variables:
+//
+//	STORE__TYPE=s3 STORE__BUCKET=x
+//
+// Values are first encoded to JSON, and the members of JSON objects (and the
+// elements of JSON arrays) are then flattened into keys, joined by Separator.
+// To encode oneof values, use FlatCodec.Wrap as the WrapFunc of their
+// registries, which inlines the fields of an option next to its discriminator:
+//
+//	fc := oneof.FlatCodec{}
+//	opts := r.JSONOptions(&oneof.Config{WrapFunc: fc.Wrap})
+//	vals, err := fc.EncodeValues("filter", &filter, opts)
+//
+// Oneof values nested within an option are flattened in the same way, e.g.,
+// filter.inner.type=range.
+//
+// When decoding, values are decoded from their text: numbers are parsed from
+// strings (see [json.StringifyNumbers]), as are values of type bool. JSON
+// object names which contain Separator cannot be decoded.
+type FlatCodec struct {
+	// Separator joins the segments of keys. If empty, defaults to ".".
+	Separator string
+
+	// DiscriminatorKey is the key segment of discriminators. If empty,
+	// defaults to "type".
+	DiscriminatorKey string
+
+	// NestedValueKey is the key segment of options whose values are not
+	// JSON objects. If empty, defaults to "value".
+	NestedValueKey string
+
+	// If UpperCase is true, keys are written in upper case, as is usual
+	// for environment variables, and matched against JSON object names
+	// case-insensitively (see [json.MatchCaseInsensitiveNames]).
+	UpperCase bool
+}
+
+// EnvCodec is a [FlatCodec] for environment variables, like STORE__TYPE=s3.
+// Its separator is a double underscore, so that snake_case names, such as
+// max_retries, can be written as STORE__MAX_RETRIES.
+var EnvCodec = FlatCodec{Separator: "__", UpperCase: true}
+
+func (c FlatCodec) separator() string {
+	if c.Separator == "" {
+		return "."
+	}
+	return c.Separator
+}
+
+func (c FlatCodec) discriminatorKey() string {
+	if c.DiscriminatorKey == "" {
+		return defaultFlatDiscriminatorKey
+	}
+	return c.DiscriminatorKey
+}
+
+func (c FlatCodec) nestedValueKey() string {
+	if c.NestedValueKey == "" {
+		return defaultFlatNestedValueKey
+	}
+	return c.NestedValueKey
+}
+
+// Wrap is a WrapFunc which can be used as the WrapFunc in [Config].
+func (c FlatCodec) Wrap(typ string, v jsontext.Value) WrappedValue {
+	return CustomValueWrapper{
+		DiscriminatorKey: c.discriminatorKey(),
+		NestedValueKey:   c.nestedValueKey(),
+		InlineObjects:    true,
+	}.Wrap(typ, v)
+}
+
+// EncodeValues encodes v as URL query parameters, whose keys start with
+// prefix. If prefix is empty, v must encode to a JSON object.
+func (c FlatCodec) EncodeValues(prefix string, v any, opts ...json.Options) (url.Values, error) {
+	pairs, err := c.flatten(prefix, v, opts...)
+	if err != nil {
+		return nil, err
+	}
+	vals := url.Values{}
+	for _, p := range pairs {
+		vals.Set(p[0], p[1])
+	}
+	return vals, nil
+}
+
+// DecodeValues decodes the URL query parameters in vals whose keys start with
+// prefix into v. Other parameters are ignored. If no parameters match, v is
+// left unchanged.
+func (c FlatCodec) DecodeValues(vals url.Values, prefix string, v any, opts ...json.Options) error {
+	var pairs [][2]string
+	for k, vs := range vals {
+		if len(vs) > 1 {
+			return fmt.Errorf("found several values for key %s", k)
+		}
+		if len(vs) == 1 {
+			pairs = append(pairs, [2]string{k, vs[0]})
+		}
+	}
+	return c.unflatten(pairs, prefix, v, opts...)
+}
+
+// EncodeEnv encodes v as environment variables, in the "key=value" form of
+// [os.Environ], sorted by key. Their keys start with prefix. If prefix is
+// empty, v must encode to a JSON object.
+func (c FlatCodec) EncodeEnv(prefix string, v any, opts ...json.Options) ([]string, error) {
+	pairs, err := c.flatten(prefix, v, opts...)
+	if err != nil {
+		return nil, err
+	}
+	env := make([]string, len(pairs))
+	for i, p := range pairs {
+		env[i] = p[0] + "=" + p[1]
+	}
+	sort.Strings(env)
+	return env, nil
+}
+
+// DecodeEnv decodes the environment variables in environ, in the "key=value"
+// form of [os.Environ], whose keys start with prefix, into v. Other variables
+// are ignored. If no variables match, v is left unchanged.
+func (c FlatCodec) DecodeEnv(environ []string, prefix string, v any, opts ...json.Options) error {
+	pairs := make([][2]string, 0, len(environ))
+	for _, kv := range environ {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+		pairs = append(pairs, [2]string{key, value})
+	}
+	return c.unflatten(pairs, prefix, v, opts...)
+}
+
+// flatten encodes v to JSON, and flattens it into key-value pairs.
+func (c FlatCodec) flatten(prefix string, v any, opts ...json.Options) ([][2]string, error) {
+	b, err := json.Marshal(v, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if prefix == "" && jsontext.Value(b).Kind() != '{' {
+		return nil, fmt.Errorf("cannot flatten %v without a prefix", jsontext.Value(b).Kind())
+	}
+
+	var pairs [][2]string
+	dec := jsontext.NewDecoder(bytes.NewReader(b))
+	if err := c.flattenValue(dec, prefix, &pairs); err != nil {
+		return nil, err
+	}
+	return pairs, nil
+}
+
+// flattenValue reads the next JSON value from dec, and appends its key-value
+// pairs under key to pairs.
+func (c FlatCodec) flattenValue(dec *jsontext.Decoder, key string, pairs *[][2]string) error {
+	join := func(segment string) (string, error) {
+		if strings.Contains(segment, c.separator()) {
+			return "", fmt.Errorf("cannot flatten name %q, which contains the separator %q", segment, c.separator())
+		}
+		if c.UpperCase {
+			segment = strings.ToUpper(segment)
+		}
+		if key == "" {
+			return segment, nil
+		}
+		return key + c.separator() + segment, nil
+	}
+
+	switch dec.PeekKind() {
+	case '{':
+		if _, err := dec.ReadToken(); err != nil {
+			return fmt.Errorf("failed to read object start token: %w", err)
+		}
+		for dec.PeekKind() != '}' {
+			tok, err := dec.ReadToken()
+			if err != nil {
+				return fmt.Errorf("failed to read object key token: %w", err)
+			}
+			k, err := join(tok.String())
+			if err != nil {
+				return err
+			}
+			if err := c.flattenValue(dec, k, pairs); err != nil {
+				return err
+			}
+		}
+		if _, err := dec.ReadToken(); err != nil {
+			return fmt.Errorf("failed to read object end token: %w", err)
+		}
+	case '[':
+		if _, err := dec.ReadToken(); err != nil {
+			return fmt.Errorf("failed to read array start token: %w", err)
+		}
+		for i := 0; dec.PeekKind() != ']'; i++ {
+			k, err := join(strconv.Itoa(i))
+			if err != nil {
+				return err
+			}
+			if err := c.flattenValue(dec, k, pairs); err != nil {
+				return err
+			}
+		}
+		if _, err := dec.ReadToken(); err != nil {
+			return fmt.Errorf("failed to read array end token: %w", err)
+		}
+	default:
+		tok, err := dec.ReadToken()
+		if err != nil {
+			return fmt.Errorf("failed to read value of %s: %w", key, err)
+		}
+		// Omit nulls, and write numbers and booleans as
+		// their JSON text
+		if tok.Kind() != 'n' {
+			*pairs = append(*pairs, [2]string{key, tok.String()})
+		}
+	}
+	return nil
+}
+
+// unflatten decodes the pairs whose keys start with prefix into v.
+func (c FlatCodec) unflatten(pairs [][2]string, prefix string, v any, opts ...json.Options) error {
+	// Decode pairs in key order, so that results do not
+	// depend on the order of their source
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i][0] < pairs[j][0] })
+
+	root := &flatNode{}
+	found := false
+	for _, p := range pairs {
+		var rest string
+		switch {
+		case p[0] == prefix:
+		case prefix == "":
+			rest = p[0]
+		case strings.HasPrefix(p[0], prefix+c.separator()):
+			rest = p[0][len(prefix)+len(c.separator()):]
+		default:
+			continue
+		}
+
+		n := root
+		if rest != "" {
+			for _, segment := range strings.Split(rest, c.separator()) {
+				n = n.child(c.name(segment))
+			}
+		}
+		if n.value != nil {
+			return fmt.Errorf("found several values for key %s", p[0])
+		}
+		value := p[1]
+		n.value = &value
+		found = true
+	}
+	if !found {
+		return nil
+	}
+
+	b, err := root.encode(prefix, c.separator())
+	if err != nil {
+		return err
+	}
+
+	// Decode numbers and booleans from strings, along with
+	// any unmarshalers in opts (which would otherwise be
+	// replaced)
+	jsonopts := json.JoinOptions(opts...)
+	unmarshalers := []*json.Unmarshalers{json.UnmarshalFuncV2(unmarshalFlatBool)}
+	if u, ok := json.GetOption(jsonopts, json.WithUnmarshalers); ok && u != nil {
+		unmarshalers = append(unmarshalers, u)
+	}
+	jsonopts = json.JoinOptions(
+		jsonopts,
+		json.StringifyNumbers(true),
+		json.MatchCaseInsensitiveNames(c.UpperCase),
+		json.WithUnmarshalers(json.NewUnmarshalers(unmarshalers...)),
+	)
+	return json.Unmarshal(b, v, jsonopts)
+}
+
+// name returns the JSON object name for the key segment s.
+func (c FlatCodec) name(s string) string {
+	if !c.UpperCase {
+		return s
+	}
+	// Discriminators and nested values are matched exactly,
+	// and other names case-insensitively
+	for _, k := range []string{c.discriminatorKey(), c.nestedValueKey()} {
+		if strings.EqualFold(s, k) {
+			return k
+		}
+	}
+	return s
+}
+
+// unmarshalFlatBool decodes a bool from a JSON string, such as "true".
+func unmarshalFlatBool(dec *jsontext.Decoder, v *bool, opts json.Options) error {
+	if dec.PeekKind() != '"' {
+		return json.SkipFunc
+	}
+	tok, err := dec.ReadToken()
+	if err != nil {
+		return err
+	}
+	b, err := strconv.ParseBool(tok.String())
+	if err != nil {
+		return fmt.Errorf("failed to decode bool: %w", err)
+	}
+	*v = b
+	return nil
+}
+
+// flatNode is a node in the tree of keys decoded by a [FlatCodec].
+type flatNode struct {
+	value    *string
+	names    []string // in order of insertion
+	children map[string]*flatNode
+}
+
+// child returns the child of n with the given name, creating it if needed.
+func (n *flatNode) child(name string) *flatNode {
+	if c, ok := n.children[name]; ok {
+		return c
+	}
+	if n.children == nil {
+		n.children = map[string]*flatNode{}
+	}
+	c := &flatNode{}
+	n.names = append(n.names, name)
+	n.children[name] = c
+	return c
+}
+
+// encode encodes n as JSON: nodes with a value as strings, nodes whose
+// children are named 0, 1, 2... as arrays, and other nodes as objects. key is
+// the key of n, and sep the separator of its children, for error messages.
+func (n *flatNode) encode(key, sep string) (jsontext.Value, error) {
+	var buf bytes.Buffer
+	enc := jsontext.NewEncoder(&buf)
+	if err := n.write(enc, key, sep); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func (n *flatNode) write(enc *jsontext.Encoder, key, sep string) error {
+	childKey := func(name string) string {
+		if key == "" {
+			return name
+		}
+		return key + sep + name
+	}
+
+	switch {
+	case n.value != nil && len(n.names) > 0:
+		return fmt.Errorf("key %s has both a value and nested keys", key)
+	case n.value != nil:
+		return enc.WriteToken(jsontext.String(*n.value))
+	case n.isArray():
+		if err := enc.WriteToken(jsontext.ArrayStart); err != nil {
+			return fmt.Errorf("failed to write array start token: %w", err)
+		}
+		for i := range n.names {
+			name := strconv.Itoa(i)
+			if err := n.children[name].write(enc, childKey(name), sep); err != nil {
+				return err
+			}
+		}
+		if err := enc.WriteToken(jsontext.ArrayEnd); err != nil {
+			return fmt.Errorf("failed to write array end token: %w", err)
+		}
+	default:
+		if err := enc.WriteToken(jsontext.ObjectStart); err != nil {
+			return fmt.Errorf("failed to write object start token: %w", err)
+		}
+		for _, name := range n.names {
+			if err := enc.WriteToken(jsontext.String(name)); err != nil {
+				return fmt.Errorf("failed to write key token %s: %w", name, err)
+			}
+			if err := n.children[name].write(enc, childKey(name), sep); err != nil {
+				return err
+			}
+		}
+		if err := enc.WriteToken(jsontext.ObjectEnd); err != nil {
+			return fmt.Errorf("failed to write object end token: %w", err)
+		}
+	}
+	return nil
+}
+
+// isArray reports whether the children of n are named 0, 1, 2...
+func (n *flatNode) isArray() bool {
+	if len(n.names) == 0 {
+		return false
+	}
+	for i := range n.names {
+		if _, ok := n.children[strconv.Itoa(i)]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,197 @@
+package oneof_test
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/dhoelle/oneof"
+)
+
+type searchFilter interface{ isSearchFilter() }
+
+type rangeFilter struct {
+	Min       int  `json:"min"`
+	Max       int  `json:"max,omitzero"`
+	Inclusive bool `json:"inclusive,omitzero"`
+}
+
+type notFilter struct {
+	Inner searchFilter `json:"inner"`
+}
+
+type anyOfFilter struct {
+	Filters []searchFilter `json:"filters"`
+}
+
+type termFilter string
+
+func (rangeFilter) isSearchFilter() {}
+func (notFilter) isSearchFilter()   {}
+func (anyOfFilter) isSearchFilter() {}
+func (termFilter) isSearchFilter()  {}
+
+type searchQuery struct {
+	Filter searchFilter `json:"filter"`
+	Limit  int          `json:"limit,omitzero"`
+}
+
+func Test_FlatCodec(t *testing.T) {
+	r := oneof.NewRegistry[searchFilter]().
+		MustRegister("range", rangeFilter{}).
+		MustRegister("not", notFilter{}).
+		MustRegister("anyOf", anyOfFilter{}).
+		MustRegister("term", termFilter(""))
+	fc := oneof.FlatCodec{}
+	opts := r.JSONOptions(&oneof.Config{WrapFunc: fc.Wrap})
+
+	tests := []struct {
+		name  string
+		in    searchQuery
+		query string
+	}{
+		{
+			name:  "object option",
+			in:    searchQuery{Filter: rangeFilter{Min: 3, Inclusive: true}, Limit: 10},
+			query: "filter.inclusive=true&filter.min=3&filter.type=range&limit=10",
+		},
+		{
+			name:  "non-object option",
+			in:    searchQuery{Filter: termFilter("go")},
+			query: "filter.type=term&filter.value=go",
+		},
+		{
+			name:  "nested option",
+			in:    searchQuery{Filter: notFilter{Inner: rangeFilter{Min: 1, Max: 2}}},
+			query: "filter.inner.max=2&filter.inner.min=1&filter.inner.type=range&filter.type=not",
+		},
+		{
+			name: "slice of options",
+			in: searchQuery{Filter: anyOfFilter{Filters: []searchFilter{
+				termFilter("go"),
+				rangeFilter{Min: 5},
+			}}},
+			query: "filter.filters.0.type=term&filter.filters.0.value=go&" +
+				"filter.filters.1.min=5&filter.filters.1.type=range&filter.type=anyOf",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vals, err := fc.EncodeValues("", tt.in, opts)
+			if err != nil {
+				t.Fatalf("error encoding: %v", err)
+			}
+			if got := vals.Encode(); got != tt.query {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.query)
+			}
+
+			want, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out searchQuery
+			if err := fc.DecodeValues(want, "", &out, opts); err != nil {
+				t.Fatalf("error decoding: %v", err)
+			}
+			if !reflect.DeepEqual(out, tt.in) {
+				t.Errorf("got %#v, want %#v", out, tt.in)
+			}
+		})
+	}
+
+	t.Run("prefix", func(t *testing.T) {
+		vals := url.Values{
+			"filter.type": {"range"},
+			"filter.min":  {"3"},
+			"page":        {"2"},
+		}
+		var f searchFilter
+		if err := fc.DecodeValues(vals, "filter", &f, opts); err != nil {
+			t.Fatalf("error decoding: %v", err)
+		}
+		if want := (rangeFilter{Min: 3}); f != want {
+			t.Errorf("got %#v, want %#v", f, want)
+		}
+
+		f = nil
+		if err := fc.DecodeValues(vals, "other", &f, opts); err != nil {
+			t.Fatalf("error decoding: %v", err)
+		}
+		if f != nil {
+			t.Errorf("got %#v, want nil", f)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for name, vals := range map[string]url.Values{
+			"unknown discriminator": {"filter.type": {"regex"}},
+			"not a number":          {"filter.type": {"range"}, "filter.min": {"x"}},
+			"not a bool":            {"filter.type": {"range"}, "filter.inclusive": {"maybe"}},
+			"several values":        {"filter.type": {"range", "term"}},
+			"value and nested keys": {"filter.type": {"range"}, "filter.min": {"1"}, "filter.min.x": {"2"}},
+		} {
+			var out searchQuery
+			if err := fc.DecodeValues(vals, "", &out, opts); err == nil {
+				t.Errorf("%s: expected an error", name)
+			}
+		}
+		if _, err := fc.EncodeValues("", "go"); err == nil {
+			t.Errorf("expected an error encoding a non-object without a prefix")
+		}
+	})
+}
+
+type envStore interface{ isEnvStore() }
+
+type envS3Store struct {
+	Bucket     string `json:"bucket"`
+	MaxRetries int    `json:"max_retries,omitzero"`
+}
+
+type envMemStore struct{}
+
+func (envS3Store) isEnvStore()  {}
+func (envMemStore) isEnvStore() {}
+
+func Test_EnvCodec(t *testing.T) {
+	r := oneof.NewRegistry[envStore]().
+		MustRegister("s3", envS3Store{}).
+		MustRegister("mem", envMemStore{})
+	opts := r.JSONOptions(&oneof.Config{WrapFunc: oneof.EnvCodec.Wrap})
+
+	var in envStore = envS3Store{Bucket: "x", MaxRetries: 3}
+	env, err := oneof.EnvCodec.EncodeEnv("STORE", &in, opts)
+	if err != nil {
+		t.Fatalf("error encoding: %v", err)
+	}
+	want := []string{"STORE__BUCKET=x", "STORE__MAX_RETRIES=3", "STORE__TYPE=s3"}
+	if !reflect.DeepEqual(env, want) {
+		t.Errorf("got %q, want %q", env, want)
+	}
+
+	// Decode from an os.Environ()-style slice with other variables
+	environ := append([]string{"HOME=/root", "STORE__TYPE_LEGACY", "STORE_TYPE=mem", "STOREFRONT=1"}, env...)
+	var out envStore
+	if err := oneof.EnvCodec.DecodeEnv(environ, "STORE", &out, opts); err != nil {
+		t.Fatalf("error decoding: %v", err)
+	}
+	if out != in {
+		t.Errorf("got %#v, want %#v", out, in)
+	}
+
+	// Valueless options are written by their discriminator alone
+	var mem envStore = envMemStore{}
+	env, err = oneof.EnvCodec.EncodeEnv("STORE", &mem, opts)
+	if err != nil {
+		t.Fatalf("error encoding: %v", err)
+	}
+	if want := []string{"STORE__TYPE=mem"}; !reflect.DeepEqual(env, want) {
+		t.Errorf("got %q, want %q", env, want)
+	}
+	if err := oneof.EnvCodec.DecodeEnv(env, "STORE", &out, opts); err != nil {
+		t.Fatalf("error decoding: %v", err)
+	}
+	if out != mem {
+		t.Errorf("got %#v, want %#v", out, mem)
+	}
+}
@@ -0,0 +1,283 @@
+package oneof
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// To produce the "default" JSON encoding of a value of type T, [MarshalFunc]
+// marshals that value again using the same options. Since
+// github.com/go-json-experiment/json applies marshal funcs to every type which
+// implements T, that inner call re-enters [MarshalFunc] for the very same
+// value. (Similarly, [UnmarshalFunc] re-enters itself if an option's type is
+// exactly T.)
+//
+// To break the recursion, the inner call is made using an encoder (or
+// decoder) which oneof creates itself. While the inner call is in progress,
+// that encoder is recorded in the sets of default coders below, along with
+// the owner of the func which started it. Funcs with that owner skip the
+// top-level value of the default coder: it is the value that they asked to
+// encode without their own treatment. Other funcs (e.g., those of a
+// [SiblingDiscriminator] for an option type) still apply to it. Values nested
+// within it are written at a greater depth, so they are wrapped as usual.
+//
+// A func may, in turn, start a default coder for the top-level value of a
+// default coder. The new coder is then also skipped by the owners of the
+// enclosing coder, since their treatment has already been applied.
+//
+// Because the recursion state belongs to the coder for a single call, rather
+// than to the MarshalFunc or UnmarshalFunc, the same [json.Options] can be
+// used from many goroutines at once, and values of the same type can be
+// nested to any depth. Coders are pooled so that the guard does not allocate
+// per value.
+//
+// Every wrapped value adds and removes a coder, so the sets are sharded by
+// the coder's address, rather than guarded by a single lock which every
+// goroutine would contend on.
+var defaultCoders [64]defaultCoderShard
+
+type defaultCoderShard struct {
+	sync.RWMutex
+	encoders map[*jsontext.Encoder]*defaultEncoder
+	decoders map[*jsontext.Decoder]*defaultDecoder
+
+	_ [64]byte // keep shards on separate cache lines
+}
+
+func init() {
+	for i := range defaultCoders {
+		defaultCoders[i].encoders = map[*jsontext.Encoder]*defaultEncoder{}
+		defaultCoders[i].decoders = map[*jsontext.Decoder]*defaultDecoder{}
+	}
+}
+
+// defaultCoderShardFor returns the shard of the coder at address p.
+func defaultCoderShardFor(p uintptr) *defaultCoderShard {
+	// Coders are large allocations, whose low address bits
+	// are alike, so mix in the high bits
+	h := uint64(p) * 0x9e3779b97f4a7c15
+	return &defaultCoders[h>>58]
+}
+
+func encoderShard(enc *jsontext.Encoder) *defaultCoderShard {
+	return defaultCoderShardFor(reflect.ValueOf(enc).Pointer())
+}
+
+func decoderShard(dec *jsontext.Decoder) *defaultCoderShard {
+	return defaultCoderShardFor(reflect.ValueOf(dec).Pointer())
+}
+
+// A coderOwner identifies the funcs which give values some treatment (e.g.,
+// wrapping them with a discriminator), and so which skip the top-level value
+// of a default coder started to encode or decode a value without it.
+type coderOwner struct {
+	name string
+}
+
+// wrapOwner owns the funcs which wrap values with discriminators. It is
+// shared by every [Registry], so that each value is wrapped at most once,
+// however many registries' funcs match it (see [JoinRegistries]).
+var wrapOwner = &coderOwner{name: "wrap"}
+
+// owns reports whether owner is one of owners.
+func owns(owners []*coderOwner, owner *coderOwner) bool {
+	for _, o := range owners {
+		if o == owner {
+			return true
+		}
+	}
+	return false
+}
+
+type defaultEncoder struct {
+	buf    bytes.Buffer
+	enc    jsontext.Encoder
+	owners []*coderOwner
+}
+
+var defaultEncoderPool = sync.Pool{
+	New: func() any { return new(defaultEncoder) },
+}
+
+type defaultDecoder struct {
+	buf    bytes.Buffer
+	dec    jsontext.Decoder
+	owners []*coderOwner
+}
+
+var defaultDecoderPool = sync.Pool{
+	New: func() any { return new(defaultDecoder) },
+}
+
+// coderOptions holds every combination of the [jsontext] options which affect
+// whether the default coders accept a JSON value, indexed by
+// [coderOptionsFor].
+//
+// The options passed to a marshal or unmarshal func carry internal state from
+// the enclosing call, which [jsontext.Encoder.Reset] and
+// [jsontext.Decoder.Reset] refuse to accept on a later reset, so the pooled
+// coders are never reset with them directly. Other coder options, like
+// indentation and escaping, do not need to be copied: the enclosing encoder
+// reformats the default encoding when it writes the wrapped value.
+var coderOptions = [4]json.Options{
+	json.JoinOptions(jsontext.AllowDuplicateNames(false), jsontext.AllowInvalidUTF8(false)),
+	json.JoinOptions(jsontext.AllowDuplicateNames(true), jsontext.AllowInvalidUTF8(false)),
+	json.JoinOptions(jsontext.AllowDuplicateNames(false), jsontext.AllowInvalidUTF8(true)),
+	json.JoinOptions(jsontext.AllowDuplicateNames(true), jsontext.AllowInvalidUTF8(true)),
+}
+
+func coderOptionsFor(opts json.Options) json.Options {
+	i := 0
+	if v, _ := json.GetOption(opts, jsontext.AllowDuplicateNames); v {
+		i |= 1
+	}
+	if v, _ := json.GetOption(opts, jsontext.AllowInvalidUTF8); v {
+		i |= 2
+	}
+	return coderOptions[i]
+}
+
+// skipEncode reports whether enc is about to write the top-level value of a
+// default encoding which a func with the given owner started (see
+// [marshalDefaultFrom]).
+func skipEncode(enc *jsontext.Encoder, owner *coderOwner) bool {
+	if enc.StackDepth() != 0 || enc.OutputOffset() != 0 {
+		return false
+	}
+	shard := encoderShard(enc)
+	shard.RLock()
+	de := shard.encoders[enc]
+	skip := de != nil && owns(de.owners, owner)
+	shard.RUnlock()
+	return skip
+}
+
+// skipDecode reports whether dec is about to read the top-level value of a
+// default decoding which a func with the given owner started (see
+// [unmarshalDefaultFrom]).
+func skipDecode(dec *jsontext.Decoder, owner *coderOwner) bool {
+	if dec.StackDepth() != 0 || dec.InputOffset() != 0 {
+		return false
+	}
+	shard := decoderShard(dec)
+	shard.RLock()
+	dd := shard.decoders[dec]
+	skip := dd != nil && owns(dd.owners, owner)
+	shard.RUnlock()
+	return skip
+}
+
+// encoderOwners returns the owners of enc, if it is a default encoder and a
+// func is handling its top-level value.
+func encoderOwners(enc *jsontext.Encoder) []*coderOwner {
+	if enc == nil || enc.StackDepth() != 0 {
+		return nil
+	}
+	shard := encoderShard(enc)
+	shard.RLock()
+	var owners []*coderOwner
+	if de := shard.encoders[enc]; de != nil {
+		owners = de.owners
+	}
+	shard.RUnlock()
+	return owners
+}
+
+// decoderOwners returns the owners of dec, if it is a default decoder and a
+// func is handling its top-level value.
+func decoderOwners(dec *jsontext.Decoder) []*coderOwner {
+	if dec == nil || dec.StackDepth() != 0 {
+		return nil
+	}
+	shard := decoderShard(dec)
+	shard.RLock()
+	var owners []*coderOwner
+	if dd := shard.decoders[dec]; dd != nil {
+		owners = dd.owners
+	}
+	shard.RUnlock()
+	return owners
+}
+
+// marshalDefault encodes v without applying oneof wrapping to v itself (values
+// nested within v are still wrapped), and passes the result to fn.
+//
+// The [jsontext.Value] passed to fn must not be retained after fn returns.
+func marshalDefault(v any, opts json.Options, fn func(jsontext.Value) error) error {
+	return marshalDefaultFrom(nil, wrapOwner, v, opts, fn)
+}
+
+// marshalDefaultFrom is like [marshalDefault], but only funcs with the given
+// owner skip v. If the func was handed v by enc (rather than encoding some
+// other value), enc must be passed, so that the owners of enc skip v too.
+func marshalDefaultFrom(enc *jsontext.Encoder, owner *coderOwner, v any, opts json.Options, fn func(jsontext.Value) error) error {
+	de := defaultEncoderPool.Get().(*defaultEncoder)
+	defer defaultEncoderPool.Put(de)
+
+	de.buf.Reset()
+	de.enc.Reset(&de.buf, coderOptionsFor(opts))
+	de.owners = append(append(de.owners[:0], encoderOwners(enc)...), owner)
+
+	shard := encoderShard(&de.enc)
+	shard.Lock()
+	shard.encoders[&de.enc] = de
+	shard.Unlock()
+
+	err := json.MarshalEncode(&de.enc, v, opts)
+
+	shard.Lock()
+	delete(shard.encoders, &de.enc)
+	shard.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	// The encoder terminates each top-level value with a newline
+	b := bytes.TrimRight(de.buf.Bytes(), "\n")
+	return fn(jsontext.Value(b))
+}
+
+// unmarshalDefault decodes b into v without applying oneof unwrapping to v
+// itself (values nested within v are still unwrapped).
+func unmarshalDefault(b jsontext.Value, v any, opts json.Options) error {
+	return unmarshalDefaultFrom(nil, wrapOwner, b, v, opts)
+}
+
+// unmarshalDefaultFrom is like [unmarshalDefault], but only funcs with the
+// given owner skip v. If the func read b from dec, dec must be passed, so that
+// the owners of dec skip v too.
+func unmarshalDefaultFrom(dec *jsontext.Decoder, owner *coderOwner, b jsontext.Value, v any, opts json.Options) error {
+	dd := defaultDecoderPool.Get().(*defaultDecoder)
+	defer defaultDecoderPool.Put(dd)
+
+	dd.buf.Reset()
+	dd.buf.Write(b)
+	dd.dec.Reset(&dd.buf, coderOptionsFor(opts))
+	dd.owners = append(append(dd.owners[:0], decoderOwners(dec)...), owner)
+
+	shard := decoderShard(&dd.dec)
+	shard.Lock()
+	shard.decoders[&dd.dec] = dd
+	shard.Unlock()
+
+	err := json.UnmarshalDecode(&dd.dec, v, opts)
+	if err == nil {
+		// Like [json.Unmarshal], reject trailing data
+		if _, err2 := dd.dec.ReadToken(); err2 != io.EOF {
+			err = fmt.Errorf("unexpected data after top-level value")
+		}
+	}
+
+	shard.Lock()
+	delete(shard.decoders, &dd.dec)
+	shard.Unlock()
+
+	return err
+}
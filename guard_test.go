@@ -0,0 +1,40 @@
+package oneof
+
+import (
+	"testing"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+func Test_DefaultCodersDoNotAllocate(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocations are not stable with the race detector enabled")
+	}
+
+	v := struct{ N int }{N: 1}
+	// Marshal and unmarshal funcs receive options in their joined form
+	opts := json.JoinOptions(json.Deterministic(true))
+	noop := func(jsontext.Value) error { return nil }
+
+	// Warm the pools
+	_ = marshalDefault(&v, opts, noop)
+	_ = unmarshalDefault(jsontext.Value(`{"N":2}`), &v, opts)
+
+	if n := testing.AllocsPerRun(100, func() {
+		if err := marshalDefault(&v, opts, noop); err != nil {
+			t.Fatal(err)
+		}
+	}); n != 0 {
+		t.Errorf("marshalDefault: got %v allocations, want 0", n)
+	}
+
+	in := jsontext.Value(`{"N":2}`)
+	if n := testing.AllocsPerRun(100, func() {
+		if err := unmarshalDefault(in, &v, opts); err != nil {
+			t.Fatal(err)
+		}
+	}); n != 0 {
+		t.Errorf("unmarshalDefault: got %v allocations, want 0", n)
+	}
+}
@@ -0,0 +1,198 @@
+package oneof
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// The default MaxBodySize of [MediaTypes]
+const defaultMaxBodySize = 10 << 20
+
+// MediaTypes maps the discriminator values of a [Registry] to HTTP media
+// types, so that the Content-Type header of a request or response, rather
+// than a field in its body, identifies the option:
+//
+//	mt, err := oneof.NewMediaTypes(r, oneof.VendorMediaType("acme"), r.JSONOptions(nil))
+//
+//	func handler(w http.ResponseWriter, req *http.Request) {
+//	  shape, err := mt.DecodeRequest(req) // e.g., Content-Type: application/vnd.acme.circle+json
+//	  ...
+//	  err = mt.Write(w, http.StatusOK, shape)
+//	}
+//
+// Bodies hold the default JSON encoding of the option, without a wrapper.
+type MediaTypes[T any] struct {
+	// MaxBodySize is the largest body, in bytes, that [MediaTypes.Decode]
+	// reads. If zero, defaults to 10 MiB. If negative, bodies are not
+	// limited (e.g., because the caller limits them with
+	// [http.MaxBytesReader]). Set it before first use.
+	MaxBodySize int64
+
+	r           *Registry[T]
+	types       typeIndex
+	byKey       map[string]string // discriminator value -> media type
+	byMediaType map[string]string // media type -> discriminator value
+	opts        json.Options
+}
+
+// VendorMediaType returns a func which maps discriminator values to vendor
+// media types, e.g., application/vnd.acme.circle+json for vendor "acme" and
+// discriminator value "circle".
+func VendorMediaType(vendor string) func(key string) string {
+	return func(key string) string {
+		return "application/vnd." + vendor + "." + key + "+json"
+	}
+}
+
+// NewMediaTypes freezes r and creates [MediaTypes] which map each of its
+// discriminator values to the media type returned by mediaType.
+//
+// opts are used to encode and decode bodies. To encode and decode oneof
+// values nested within bodies, include, e.g., [Registry.JSONOptions].
+//
+// NewMediaTypes returns an error if mediaType returns an invalid media type,
+// or the same media type for several discriminator values.
+func NewMediaTypes[T any](r *Registry[T], mediaType func(key string) string, opts ...json.Options) (*MediaTypes[T], error) {
+	r.Freeze()
+
+	m := &MediaTypes[T]{
+		r:           r,
+		types:       r.typeIndex(false),
+		byKey:       map[string]string{},
+		byMediaType: map[string]string{},
+		opts:        json.JoinOptions(opts...),
+	}
+	for _, key := range r.Keys() {
+		mt := mediaType(key)
+		parsed, _, err := mime.ParseMediaType(mt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid media type %q for key %s: %w", mt, key, err)
+		}
+		if existing, ok := m.byMediaType[parsed]; ok {
+			return nil, fmt.Errorf("duplicate media type %s for key %s (already used for key %s)", parsed, key, existing)
+		}
+		m.byKey[key] = mt
+		m.byMediaType[parsed] = key
+	}
+	return m, nil
+}
+
+// MediaType returns the media type of the option of v.
+func (m *MediaTypes[T]) MediaType(v T) (string, error) {
+	key, ok := m.types.keyFor(v)
+	if !ok {
+		return "", ErrUnknownGoType{typ: fmt.Sprintf("%T", v)}
+	}
+	return m.byKey[key], nil
+}
+
+// Decode decodes body into a new value of the option whose media type is
+// contentType. Media type parameters (e.g., charset) are ignored.
+//
+// If contentType is not the media type of any option, Decode returns
+// [ErrUnknownDiscriminatorValue]. Decode returns an error, without reading
+// the rest of body, if body is larger than MaxBodySize.
+func (m *MediaTypes[T]) Decode(contentType string, body io.Reader) (T, error) {
+	var t T
+
+	parsed, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return t, fmt.Errorf("invalid media type %q: %w", contentType, err)
+	}
+	key, ok := m.byMediaType[parsed]
+	if !ok {
+		return t, ErrUnknownDiscriminatorValue{v: parsed}
+	}
+
+	limit := m.MaxBodySize
+	if limit == 0 {
+		limit = defaultMaxBodySize
+	}
+	if limit > 0 {
+		body = io.LimitReader(body, limit+1)
+	}
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return t, fmt.Errorf("failed to read body: %w", err)
+	}
+	if limit > 0 && int64(len(b)) > limit {
+		return t, fmt.Errorf("body is larger than %d bytes", limit)
+	}
+	if len(bytes.TrimSpace(b)) == 0 {
+		return t, fmt.Errorf("empty body for media type %s", parsed)
+	}
+	if err := m.r.decodeOption(&t, key, jsontext.Value(b), nil, m.opts); err != nil {
+		return t, err
+	}
+	return t, nil
+}
+
+// DecodeRequest decodes the body of req, according to its Content-Type
+// header. See [MediaTypes.Decode].
+func (m *MediaTypes[T]) DecodeRequest(req *http.Request) (T, error) {
+	return m.Decode(req.Header.Get("Content-Type"), req.Body)
+}
+
+// Encode returns the media type of v, and its default JSON encoding.
+func (m *MediaTypes[T]) Encode(v T) (string, []byte, error) {
+	mt, err := m.MediaType(v)
+	if err != nil {
+		return "", nil, err
+	}
+	var b []byte
+	err = marshalDefault(v, m.opts, func(jv jsontext.Value) error {
+		b = bytes.Clone(jv)
+		return nil
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal v: %w", err)
+	}
+	return mt, b, nil
+}
+
+// Write writes v to w with the given status code, setting the Content-Type
+// header to the media type of v.
+func (m *MediaTypes[T]) Write(w http.ResponseWriter, status int, v T) error {
+	mt, b, err := m.Encode(v)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", mt)
+	w.WriteHeader(status)
+	if _, err := w.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("failed to write body: %w", err)
+	}
+	return nil
+}
+
+// NewRequest creates an HTTP request whose body is v, with the Content-Type
+// header set to the media type of v.
+func (m *MediaTypes[T]) NewRequest(method, url string, v T) (*http.Request, error) {
+	mt, b, err := m.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, url, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", mt)
+	return req, nil
+}
+
+// Accept returns the media types of all options, for use in an Accept header.
+func (m *MediaTypes[T]) Accept() string {
+	keys := m.r.Keys()
+	mts := make([]string, len(keys))
+	for i, key := range keys {
+		mts[i] = m.byKey[key]
+	}
+	return strings.Join(mts, ", ")
+}
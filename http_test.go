@@ -0,0 +1,159 @@
+package oneof_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dhoelle/oneof"
+)
+
+func Test_MediaTypes(t *testing.T) {
+	r := oneof.NewRegistry[fmt.Stringer]().
+		MustRegister("literal", LiteralStringer("")).
+		MustRegister("join", JoinStringer{}).
+		MustRegister("exclamation", ExclamationPointsStringer(0))
+	mt, err := oneof.NewMediaTypes(r, oneof.VendorMediaType("acme"), r.JSONOptions(nil))
+	if err != nil {
+		t.Fatalf("error creating media types: %v", err)
+	}
+
+	// The server echoes the decoded value back to the client
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		v, err := mt.DecodeRequest(req)
+		if err != nil {
+			var unknown oneof.ErrUnknownDiscriminatorValue
+			if errors.As(err, &unknown) {
+				http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := mt.Write(w, http.StatusOK, v); err != nil {
+			t.Errorf("error writing response: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	t.Run("round trip", func(t *testing.T) {
+		in := JoinStringer{A: LiteralStringer("hello"), B: ExclamationPointsStringer(2)}
+		req, err := mt.NewRequest(http.MethodPost, srv.URL, in)
+		if err != nil {
+			t.Fatalf("error creating request: %v", err)
+		}
+		if got, want := req.Header.Get("Content-Type"), "application/vnd.acme.join+json"; got != want {
+			t.Errorf("request Content-Type: got %q, want %q", got, want)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("error sending request: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			b, _ := io.ReadAll(resp.Body)
+			t.Fatalf("got status %d: %s", resp.StatusCode, b)
+		}
+		if got, want := resp.Header.Get("Content-Type"), "application/vnd.acme.join+json"; got != want {
+			t.Errorf("response Content-Type: got %q, want %q", got, want)
+		}
+
+		out, err := mt.Decode(resp.Header.Get("Content-Type"), resp.Body)
+		if err != nil {
+			t.Fatalf("error decoding response: %v", err)
+		}
+		if out != in {
+			t.Errorf("got %#v, want %#v", out, in)
+		}
+	})
+
+	t.Run("body is not wrapped", func(t *testing.T) {
+		contentType, b, err := mt.Encode(JoinStringer{A: LiteralStringer("a")})
+		if err != nil {
+			t.Fatalf("error encoding: %v", err)
+		}
+		if want := "application/vnd.acme.join+json"; contentType != want {
+			t.Errorf("media type: got %q, want %q", contentType, want)
+		}
+		if want := `{"a":{"_type":"literal","_value":"a"}}`; string(b) != want {
+			t.Errorf("body: got %s, want %s", b, want)
+		}
+	})
+
+	t.Run("media type parameters are ignored", func(t *testing.T) {
+		v, err := mt.Decode("application/vnd.acme.exclamation+json; charset=utf-8", strings.NewReader("3"))
+		if err != nil {
+			t.Fatalf("error decoding: %v", err)
+		}
+		if v != ExclamationPointsStringer(3) {
+			t.Errorf("got %#v, want %#v", v, ExclamationPointsStringer(3))
+		}
+	})
+
+	t.Run("unknown media type", func(t *testing.T) {
+		resp, err := http.Post(srv.URL, "application/json", strings.NewReader(`"x"`))
+		if err != nil {
+			t.Fatalf("error sending request: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusUnsupportedMediaType {
+			t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusUnsupportedMediaType)
+		}
+	})
+
+	t.Run("empty body", func(t *testing.T) {
+		if _, err := mt.Decode("application/vnd.acme.literal+json", strings.NewReader("")); err == nil {
+			t.Errorf("expected an error")
+		}
+	})
+
+	t.Run("max body size", func(t *testing.T) {
+		mt, err := oneof.NewMediaTypes(r, oneof.VendorMediaType("acme"), r.JSONOptions(nil))
+		if err != nil {
+			t.Fatalf("error creating media types: %v", err)
+		}
+		mt.MaxBodySize = 4
+
+		v, err := mt.Decode("application/vnd.acme.literal+json", strings.NewReader(`"hi"`))
+		if err != nil {
+			t.Fatalf("error decoding: %v", err)
+		}
+		if v != LiteralStringer("hi") {
+			t.Errorf("got %#v, want %#v", v, LiteralStringer("hi"))
+		}
+		if _, err := mt.Decode("application/vnd.acme.literal+json", strings.NewReader(`"hello"`)); err == nil {
+			t.Errorf("expected an error")
+		}
+	})
+
+	t.Run("unknown Go type", func(t *testing.T) {
+		_, err := mt.MediaType(time.Second)
+		var unknown oneof.ErrUnknownGoType
+		if !errors.As(err, &unknown) {
+			t.Errorf("got error %v, want ErrUnknownGoType", err)
+		}
+	})
+
+	t.Run("accept", func(t *testing.T) {
+		want := "application/vnd.acme.literal+json, application/vnd.acme.join+json, application/vnd.acme.exclamation+json"
+		if got := mt.Accept(); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+}
+
+func Test_NewMediaTypes_duplicate(t *testing.T) {
+	r := oneof.NewRegistry[fmt.Stringer]().
+		MustRegister("literal", LiteralStringer("")).
+		MustRegister("join", JoinStringer{})
+	_, err := oneof.NewMediaTypes(r, func(string) string { return "application/json" })
+	if err == nil {
+		t.Errorf("expected an error for duplicate media types")
+	}
+}
@@ -0,0 +1,254 @@
+package oneof
+
+import (
+	"fmt"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// The WrapFuncs below reproduce the polymorphic JSON conventions of other
+// ecosystems, for use as [Config.WrapFunc]. Each of them inlines the members of
+// JSON object values next to a discriminator property, and, when
+// unmarshaling, finds the discriminator anywhere in the object. They differ in
+// where the discriminator is written, whether it is part of the value, and how
+// values which are not JSON objects are handled.
+//
+// In every convention, a nil value of T is written as a JSON null, and a JSON
+// null unmarshals to a nil T.
+
+// WrapJacksonClass reproduces Jackson's
+// @JsonTypeInfo(use = Id.CLASS, include = As.PROPERTY), registering options
+// under their Java class names:
+//
+//	{"@class": "com.example.Dog", "name": "Rex"}
+//
+// As in Jackson, values which are not JSON objects are written as a
+// two-element array (see [WrapTuple]), e.g., ["com.example.Name", "Rex"].
+func WrapJacksonClass(typ string, v jsontext.Value) WrappedValue {
+	return propertyWrappedValue{preset: &jacksonClassPreset, typ: typ, value: v}
+}
+
+// WrapJacksonType is like [WrapJacksonClass], but reproduces Jackson's
+// @JsonTypeInfo(use = Id.NAME), whose property is "@type":
+//
+//	{"@type": "Dog", "name": "Rex"}
+func WrapJacksonType(typ string, v jsontext.Value) WrappedValue {
+	return propertyWrappedValue{preset: &jacksonTypePreset, typ: typ, value: v}
+}
+
+// PydanticWrapper builds a WrapFunc which reproduces Pydantic's discriminated
+// unions, e.g., Field(discriminator="pet_type"):
+//
+//	cfg := &oneof.Config{
+//	  WrapFunc: oneof.PydanticWrapper{Discriminator: "pet_type"}.Wrap,
+//	}
+//
+// produces JSON like:
+//
+//	{"pet_type": "dog", "name": "Rex"}
+//
+// As in Pydantic, the discriminator is a field of the value: when
+// unmarshaling, it is also decoded into the value (e.g., into a PetType field
+// tagged `json:"pet_type"`), and when marshaling, such a field must be empty
+// or hold the discriminator value. Only JSON object values are supported.
+type PydanticWrapper struct {
+	// Discriminator is the name of the discriminator field.
+	// If empty, defaults to "type".
+	Discriminator string
+}
+
+// Wrap is a WrapFunc which can be used as the WrapFunc in [Config].
+func (p PydanticWrapper) Wrap(typ string, v jsontext.Value) WrappedValue {
+	preset := &pydanticPreset
+	if p.Discriminator != "" {
+		preset = &propertyPreset{name: "Pydantic", key: p.Discriminator, keepTag: true}
+	}
+	return propertyWrappedValue{preset: preset, typ: typ, value: v}
+}
+
+// WrapGraphQL reproduces GraphQL's __typename meta field:
+//
+//	{"__typename": "Droid", "name": "R2-D2", "primaryFunction": "Astromech"}
+//
+// As in GraphQL, __typename is a field of the value: when unmarshaling, it is
+// also decoded into the value (e.g., into a Typename field tagged
+// `json:"__typename"`), and when marshaling, such a field must be empty or hold
+// the discriminator value. Only JSON object values are supported.
+func WrapGraphQL(typ string, v jsontext.Value) WrappedValue {
+	return propertyWrappedValue{preset: &graphQLPreset, typ: typ, value: v}
+}
+
+// WrapJSONLD reproduces the @type keyword of JSON-LD node objects:
+//
+//	{"@context": "https://schema.org", "@type": "Person", "name": "Jane Doe"}
+//
+// @type is written after the @context and @id keywords, if the value has
+// them, and first otherwise. Those keywords are left in the value. When
+// unmarshaling, @type may also be an array of types, whose first element is
+// the discriminator. Only JSON object values are supported.
+func WrapJSONLD(typ string, v jsontext.Value) WrappedValue {
+	return propertyWrappedValue{preset: &jsonLDPreset, typ: typ, value: v}
+}
+
+// WrapMongo reproduces the "_t" discriminator convention of MongoDB drivers:
+//
+//	{"_id": 1, "_t": "Cat", "name": "Tom"}
+//
+// As in the MongoDB C# driver, _t is written after _id, if the value has one,
+// and first otherwise. When unmarshaling, _t may also be an array holding a
+// class hierarchy (e.g., ["Animal", "Cat"]), whose last element is the
+// discriminator. Only JSON object values are supported.
+func WrapMongo(typ string, v jsontext.Value) WrappedValue {
+	return propertyWrappedValue{preset: &mongoPreset, typ: typ, value: v}
+}
+
+// propertyPreset describes a convention which stores the discriminator in a
+// property of a JSON object.
+type propertyPreset struct {
+	name string // for error messages
+	key  string
+
+	// If keepTag is true, the discriminator is a member of the
+	// value.
+	keepTag bool
+
+	// The discriminator is written after the last of the members
+	// in after which the value has, or else first.
+	after []string
+
+	// arrayTag selects the element of an array of discriminators:
+	// 0 if arrays are not allowed, 1 for the first element, and
+	// -1 for the last.
+	arrayTag int
+
+	// If tupleNonObjects is true, values which are not JSON
+	// objects are written as [WrapTuple] arrays. Otherwise, they
+	// cannot be marshaled.
+	tupleNonObjects bool
+}
+
+var (
+	jacksonClassPreset = propertyPreset{name: "Jackson", key: "@class", tupleNonObjects: true}
+	jacksonTypePreset  = propertyPreset{name: "Jackson", key: "@type", tupleNonObjects: true}
+	pydanticPreset     = propertyPreset{name: "Pydantic", key: "type", keepTag: true}
+	graphQLPreset      = propertyPreset{name: "GraphQL", key: "__typename", keepTag: true}
+	jsonLDPreset       = propertyPreset{name: "JSON-LD", key: "@type", after: []string{"@context", "@id"}, arrayTag: 1}
+	mongoPreset        = propertyPreset{name: "MongoDB", key: "_t", after: []string{"_id"}, arrayTag: -1}
+)
+
+// propertyWrappedValue is the [WrappedValue] of a [propertyPreset].
+type propertyWrappedValue struct {
+	preset *propertyPreset
+	typ    string
+	value  jsontext.Value
+	tok    jsontext.Value
+}
+
+func (w propertyWrappedValue) Type() string                { return w.typ }
+func (w propertyWrappedValue) Value() jsontext.Value       { return w.value }
+func (w propertyWrappedValue) token() jsontext.Value       { return w.tok }
+func (w propertyWrappedValue) discriminatorMember() string { return w.preset.key }
+
+func (w propertyWrappedValue) MarshalJSONV2(enc *jsontext.Encoder, opts json.Options) error {
+	p := w.preset
+	dv, err := json.Marshal(w.typ)
+	if err != nil {
+		return fmt.Errorf("failed to encode discriminator value %s: %w", w.typ, err)
+	}
+	tag := jsonMember{name: p.key, value: jsontext.Value(dv)}
+
+	switch {
+	case len(w.value) == 0:
+		return jsonObject{tag}.encode(enc)
+	case w.value.Kind() != '{' && p.tupleNonObjects:
+		return tupleWrappedValue{typ: w.typ, value: w.value}.MarshalJSONV2(enc, opts)
+	case w.value.Kind() != '{':
+		return fmt.Errorf("%s discriminators only support JSON object values (got %v)", p.name, w.value.Kind())
+	}
+
+	var root jsonObject
+	if err := root.parse(w.value); err != nil {
+		return fmt.Errorf("failed to parse value: %w", err)
+	}
+
+	if i := root.index(p.key); i >= 0 {
+		// The value has a field for the discriminator,
+		// which may be empty
+		if !p.keepTag {
+			return fmt.Errorf("value already contains discriminator %s", p.key)
+		}
+		var s string
+		jv, ok := root[i].value.(jsontext.Value)
+		if !ok || jv.Kind() != '"' || json.Unmarshal(jv, &s) != nil || (s != "" && s != w.typ) {
+			return fmt.Errorf("value has %s %s, want %q", p.key, encodeJSONTree(root[i].value), w.typ)
+		}
+		root[i] = tag
+		return root.encode(enc)
+	}
+
+	i := 0
+	for _, name := range p.after {
+		i = max(i, root.index(name)+1)
+	}
+	root = append(root[:i], append(jsonObject{tag}, root[i:]...)...)
+	return root.encode(enc)
+}
+
+func (w *propertyWrappedValue) UnmarshalJSONV2(dec *jsontext.Decoder, opts json.Options) error {
+	p := w.preset
+	if p.tupleNonObjects && dec.PeekKind() == '[' {
+		var t tupleWrappedValue
+		if err := t.UnmarshalJSONV2(dec, opts); err != nil {
+			return err
+		}
+		w.typ, w.value = t.typ, t.value
+		return nil
+	}
+	if k := dec.PeekKind(); k != '{' {
+		return fmt.Errorf("expected object start, but encountered %v", k)
+	}
+
+	var root jsonObject
+	if err := root.decode(dec); err != nil {
+		return err
+	}
+
+	var dv any
+	var ok bool
+	if p.keepTag {
+		dv, ok = root.get([]string{p.key})
+	} else {
+		dv, ok = root.take([]string{p.key})
+	}
+	if !ok {
+		return fmt.Errorf(`missing discriminator "%s"`, p.key)
+	}
+	jv, _ := dv.(jsontext.Value)
+	switch {
+	case jv.Kind() == '"':
+		if err := json.Unmarshal(jv, &w.typ); err != nil {
+			return fmt.Errorf("failed to decode discriminator: %w", err)
+		}
+	case jv.Kind() == '[' && p.arrayTag != 0:
+		var types []string
+		if err := json.Unmarshal(jv, &types); err != nil || len(types) == 0 {
+			return fmt.Errorf(`value for discriminator "%s" must be a string or a non-empty array of strings`, p.key)
+		}
+		if p.arrayTag > 0 {
+			w.typ = types[0]
+		} else {
+			w.typ = types[len(types)-1]
+		}
+	case p.arrayTag != 0:
+		return fmt.Errorf(`value for discriminator "%s" must be a string or a non-empty array of strings`, p.key)
+	default:
+		return fmt.Errorf(`value for discriminator "%s" must be a string`, p.key)
+	}
+	w.tok = jv
+
+	if len(root) > 0 {
+		w.value = encodeJSONTree(root)
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package oneof_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dhoelle/oneof"
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// Jackson and MongoDB
+type animal interface{ isAnimal() }
+
+type dog struct {
+	ID         int    `json:"_id,omitzero"`
+	Name       string `json:"name"`
+	BarkVolume int    `json:"barkVolume,omitzero"`
+}
+
+type cat struct {
+	Name  string `json:"name"`
+	Lives int    `json:"lives,omitzero"`
+}
+
+type petName string
+
+func (dog) isAnimal()     {}
+func (cat) isAnimal()     {}
+func (petName) isAnimal() {}
+
+// Pydantic
+type pet interface{ isPet() }
+
+type pydanticCat struct {
+	PetType string `json:"pet_type"`
+	Meows   int    `json:"meows"`
+}
+
+type pydanticDog struct {
+	PetType string  `json:"pet_type"`
+	Barks   float64 `json:"barks"`
+}
+
+func (pydanticCat) isPet() {}
+func (pydanticDog) isPet() {}
+
+// GraphQL
+type character interface{ isCharacter() }
+
+type human struct {
+	Name   string  `json:"name"`
+	Height float64 `json:"height"`
+}
+
+type droid struct {
+	Typename        string `json:"__typename,omitempty"`
+	Name            string `json:"name"`
+	PrimaryFunction string `json:"primaryFunction"`
+}
+
+func (human) isCharacter() {}
+func (droid) isCharacter() {}
+
+// JSON-LD
+type thing interface{ isThing() }
+
+type person struct {
+	Context  string `json:"@context,omitempty"`
+	Name     string `json:"name"`
+	JobTitle string `json:"jobTitle,omitempty"`
+}
+
+type organization struct {
+	Context string `json:"@context,omitempty"`
+	ID      string `json:"@id,omitempty"`
+	Name    string `json:"name"`
+	URL     string `json:"url"`
+}
+
+func (person) isThing()       {}
+func (organization) isThing() {}
+
+func Test_InteropPresets(t *testing.T) {
+	animals := oneof.NewRegistry[animal]().
+		MustRegister("Dog", dog{}).
+		MustRegister("Cat", cat{}).
+		MustRegister("PetName", petName(""))
+	javaAnimals := oneof.NewRegistry[animal]().
+		MustRegister("com.example.Dog", dog{}).
+		MustRegister("com.example.Cat", cat{}).
+		MustRegister("com.example.PetName", petName(""))
+	pets := oneof.NewRegistry[pet]().
+		MustRegister("cat", pydanticCat{}).
+		MustRegister("dog", pydanticDog{})
+	characters := oneof.NewRegistry[character]().
+		MustRegister("Human", human{}).
+		MustRegister("Droid", droid{})
+	things := oneof.NewRegistry[thing]().
+		MustRegister("Person", person{}).
+		MustRegister("Organization", organization{})
+
+	t.Run("jackson class", func(t *testing.T) {
+		opts := javaAnimals.JSONOptions(&oneof.Config{WrapFunc: oneof.WrapJacksonClass})
+		in := []animal{dog{Name: "Rex", BarkVolume: 11}, cat{Name: "Tom", Lives: 9}, petName("Felix")}
+		testGolden(t, "jackson_class.json", opts, in, in)
+
+		// Jackson finds the type property anywhere in an object
+		var out []animal
+		if err := json.Unmarshal([]byte(`[{"name":"Rex","@class":"com.example.Dog"}]`), &out, opts); err != nil {
+			t.Fatalf("error unmarshaling: %v", err)
+		}
+		if want := []animal{dog{Name: "Rex"}}; !reflect.DeepEqual(out, want) {
+			t.Errorf("got %#v, want %#v", out, want)
+		}
+	})
+
+	t.Run("jackson type", func(t *testing.T) {
+		opts := animals.JSONOptions(&oneof.Config{WrapFunc: oneof.WrapJacksonType})
+		in := []animal{dog{Name: "Rex", BarkVolume: 11}, cat{Name: "Tom", Lives: 9}, petName("Felix")}
+		testGolden(t, "jackson_type.json", opts, in, in)
+	})
+
+	t.Run("pydantic", func(t *testing.T) {
+		opts := pets.JSONOptions(&oneof.Config{WrapFunc: oneof.PydanticWrapper{Discriminator: "pet_type"}.Wrap})
+		in := []pet{pydanticCat{Meows: 4}, pydanticDog{Barks: 3.14}}
+		want := []pet{pydanticCat{PetType: "cat", Meows: 4}, pydanticDog{PetType: "dog", Barks: 3.14}}
+		testGolden(t, "pydantic.json", opts, in, want)
+
+		// A discriminator field must match the option
+		if _, err := json.Marshal([]pet{pydanticCat{PetType: "dog"}}, opts); err == nil {
+			t.Errorf("expected an error for a mismatched discriminator field")
+		}
+	})
+
+	t.Run("graphql", func(t *testing.T) {
+		opts := characters.JSONOptions(&oneof.Config{WrapFunc: oneof.WrapGraphQL})
+		in := []character{
+			human{Name: "Luke Skywalker", Height: 1.72},
+			droid{Name: "R2-D2", PrimaryFunction: "Astromech"},
+		}
+		want := []character{
+			human{Name: "Luke Skywalker", Height: 1.72},
+			droid{Typename: "Droid", Name: "R2-D2", PrimaryFunction: "Astromech"},
+		}
+		testGolden(t, "graphql.json", opts, in, want)
+	})
+
+	t.Run("json-ld", func(t *testing.T) {
+		opts := things.JSONOptions(&oneof.Config{WrapFunc: oneof.WrapJSONLD})
+		in := []thing{
+			person{Context: "https://schema.org", Name: "Jane Doe", JobTitle: "Professor"},
+			organization{Context: "https://schema.org", ID: "https://example.com/#org", Name: "Example", URL: "https://example.com"},
+		}
+		testGolden(t, "jsonld.json", opts, in, in)
+
+		// @type may be an array, whose first element is used
+		var out []thing
+		if err := json.Unmarshal([]byte(`[{"@type":["Person","Patient"],"name":"Jane Doe"}]`), &out, opts); err != nil {
+			t.Fatalf("error unmarshaling: %v", err)
+		}
+		if want := []thing{person{Name: "Jane Doe"}}; !reflect.DeepEqual(out, want) {
+			t.Errorf("got %#v, want %#v", out, want)
+		}
+	})
+
+	t.Run("mongo", func(t *testing.T) {
+		opts := animals.JSONOptions(&oneof.Config{WrapFunc: oneof.WrapMongo})
+		in := []animal{dog{ID: 1, Name: "Rex", BarkVolume: 11}, cat{Name: "Tom", Lives: 9}}
+		testGolden(t, "mongo.json", opts, in, in)
+
+		// _t may hold a class hierarchy, whose last element is used
+		var out []animal
+		if err := json.Unmarshal([]byte(`[{"_id":2,"_t":["Animal","Dog"],"name":"Rex"}]`), &out, opts); err != nil {
+			t.Fatalf("error unmarshaling: %v", err)
+		}
+		if want := []animal{dog{ID: 2, Name: "Rex"}}; !reflect.DeepEqual(out, want) {
+			t.Errorf("got %#v, want %#v", out, want)
+		}
+	})
+
+	t.Run("non-object values", func(t *testing.T) {
+		for name, wrapFunc := range map[string]func(string, jsontext.Value) oneof.WrappedValue{
+			"pydantic": oneof.PydanticWrapper{}.Wrap,
+			"graphql":  oneof.WrapGraphQL,
+			"json-ld":  oneof.WrapJSONLD,
+			"mongo":    oneof.WrapMongo,
+		} {
+			opts := animals.JSONOptions(&oneof.Config{WrapFunc: wrapFunc})
+			if _, err := json.Marshal([]animal{petName("Felix")}, opts); err == nil {
+				t.Errorf("%s: expected an error marshaling a non-object value", name)
+			}
+		}
+	})
+
+	t.Run("nulls", func(t *testing.T) {
+		opts := animals.JSONOptions(&oneof.Config{WrapFunc: oneof.WrapMongo})
+		b, err := json.Marshal([]animal{nil}, opts)
+		if err != nil {
+			t.Fatalf("error marshaling: %v", err)
+		}
+		if string(b) != "[null]" {
+			t.Errorf("got %s, want [null]", b)
+		}
+	})
+
+	t.Run("unknown discriminator", func(t *testing.T) {
+		opts := animals.JSONOptions(&oneof.Config{WrapFunc: oneof.WrapMongo})
+		var out []animal
+		err := json.Unmarshal([]byte(`[{"_t":["Animal","Bird"]}]`), &out, opts)
+		var unknown oneof.ErrUnknownDiscriminatorValue
+		if !errors.As(err, &unknown) {
+			t.Fatalf("got error %v, want ErrUnknownDiscriminatorValue", err)
+		}
+		if got, want := string(unknown.Token()), `["Animal","Bird"]`; got != want {
+			t.Errorf("got token %s, want %s", got, want)
+		}
+	})
+}
+
+// testGolden checks that in marshals to the JSON in testdata/interop/name,
+// and that the JSON unmarshals to want.
+func testGolden[T any](t *testing.T, name string, opts json.Options, in, want []T) {
+	t.Helper()
+
+	golden, err := os.ReadFile(filepath.Join("testdata", "interop", name))
+	if err != nil {
+		t.Fatalf("error reading golden file: %v", err)
+	}
+	compact := jsontext.Value(append([]byte(nil), golden...))
+	if err := compact.Compact(); err != nil {
+		t.Fatalf("error compacting golden file: %v", err)
+	}
+
+	b, err := json.Marshal(in, opts)
+	if err != nil {
+		t.Fatalf("error marshaling: %v", err)
+	}
+	if string(b) != string(compact) {
+		t.Errorf("marshal: got:\n%s\nwant:\n%s", b, compact)
+	}
+
+	var out []T
+	if err := json.Unmarshal(golden, &out, opts); err != nil {
+		t.Fatalf("error unmarshaling: %v", err)
+	}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("unmarshal: got %#v, want %#v", out, want)
+	}
+}
@@ -0,0 +1,78 @@
+package oneof
+
+import (
+	"reflect"
+
+	"github.com/go-json-experiment/json"
+)
+
+// AnyRegistry is implemented by [Registry] types for any interface type T, so
+// that registries for several interfaces can be passed to [JoinRegistries].
+type AnyRegistry interface {
+	typeIndex(strict bool) typeIndex
+	marshalFunc(cfg *Config, joined *typeIndex) *json.Marshalers
+	UnmarshalFunc(cfg *Config) *json.Unmarshalers
+	Freeze()
+}
+
+// JoinRegistries freezes each of rs and returns [json.Options] which marshal
+// and unmarshal values of each of their interface types.
+//
+// Use JoinRegistries rather than joining the options of several registries
+// when a Go type may implement more than one of the interfaces. For example,
+// given registries for interfaces Source and Sink, a type implementing both
+// would, by default, be intercepted by whichever marshal func
+// [github.com/go-json-experiment/json] tries first, and would fail to marshal
+// if that registry does not include it. The options returned by
+// JoinRegistries look up a value's discriminator in all of rs, and wrap each
+// value exactly once, however the marshal funcs are ordered.
+//
+// Where a value's static type is one of the interface types (e.g., a field of
+// type Source), it must be an option of that interface's registry, so that it
+// can be unmarshaled again: if it is not, marshaling fails with
+// [ErrUnknownGoType].
+//
+// JoinRegistries returns [ErrConflictingDiscriminators] if the same Go type is
+// registered under different discriminator values in different registries.
+func JoinRegistries(cfg *Config, rs ...AnyRegistry) (json.Options, error) {
+	strict := cfg != nil && cfg.StrictTypes
+
+	types := typeIndex{strict: strict}
+	for _, r := range rs {
+		r.Freeze()
+		if err := types.merge(r.typeIndex(strict)); err != nil {
+			return nil, err
+		}
+	}
+
+	var marshalers []*json.Marshalers
+	var unmarshalers []*json.Unmarshalers
+	for _, r := range rs {
+		marshalers = append(marshalers, r.marshalFunc(cfg, &types))
+		unmarshalers = append(unmarshalers, r.UnmarshalFunc(cfg))
+	}
+
+	return json.JoinOptions(
+		json.WithMarshalers(json.NewMarshalers(marshalers...)),
+		json.WithUnmarshalers(json.NewUnmarshalers(unmarshalers...)),
+	), nil
+}
+
+// merge adds the entries of other to idx. It returns an error if a Go type
+// maps to different discriminator values in idx and other.
+func (idx *typeIndex) merge(other typeIndex) error {
+	if idx.keys == nil {
+		idx.keys = make(map[reflect.Type]string, len(other.keys))
+	}
+	for typ, key := range other.keys {
+		existing, ok := idx.keys[typ]
+		if !ok {
+			idx.keys[typ] = key
+			continue
+		}
+		if existing != key {
+			return ErrConflictingDiscriminators{typ: typ.String(), key: key, existingKey: existing}
+		}
+	}
+	return nil
+}
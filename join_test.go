@@ -0,0 +1,125 @@
+package oneof_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dhoelle/oneof"
+	"github.com/go-json-experiment/json"
+)
+
+type testSource interface{ Read() string }
+type testSink interface{ Write(string) }
+
+type fileSource struct {
+	Path string `json:"path"`
+}
+
+func (fileSource) Read() string { return "" }
+
+// pipe is both a testSource and a testSink
+type pipe struct {
+	Name string `json:"name"`
+}
+
+func (pipe) Read() string { return "" }
+func (pipe) Write(string) {}
+
+// tee implements testSource, but is only registered as a testSink
+type tee struct{}
+
+func (tee) Read() string { return "" }
+func (tee) Write(string) {}
+
+type nullSink struct{}
+
+func (nullSink) Write(string) {}
+
+type pipeline struct {
+	Source testSource `json:"source"`
+	Sink   testSink   `json:"sink"`
+}
+
+func Test_JoinRegistries(t *testing.T) {
+	sources := oneof.NewRegistry[testSource]().
+		MustRegister("file", fileSource{}).
+		MustRegister("pipe", pipe{})
+	sinks := oneof.NewRegistry[testSink]().
+		MustRegister("pipe", pipe{}).
+		MustRegister("null", nullSink{}).
+		MustRegister("tee", tee{})
+
+	in := []pipeline{
+		{Source: pipe{Name: "a"}, Sink: pipe{Name: "b"}},
+		{Source: fileSource{Path: "/tmp/x"}, Sink: nullSink{}},
+		{Source: fileSource{Path: "/tmp/y"}, Sink: tee{}},
+	}
+
+	// Without joining the registries, the testSource marshal
+	// func intercepts the tee, and fails
+	naive := json.WithMarshalers(json.NewMarshalers(sources.MarshalFunc(nil), sinks.MarshalFunc(nil)))
+	if _, err := json.Marshal(in, naive); !errors.As(err, &oneof.ErrUnknownGoType{}) {
+		t.Errorf("got error %v, want ErrUnknownGoType", err)
+	}
+
+	want := `[` +
+		`{"source":{"_type":"pipe","_value":{"name":"a"}},"sink":{"_type":"pipe","_value":{"name":"b"}}},` +
+		`{"source":{"_type":"file","_value":{"path":"/tmp/x"}},"sink":{"_type":"null"}},` +
+		`{"source":{"_type":"file","_value":{"path":"/tmp/y"}},"sink":{"_type":"tee"}}` +
+		`]`
+
+	for _, order := range [][]oneof.AnyRegistry{{sources, sinks}, {sinks, sources}} {
+		opts, err := oneof.JoinRegistries(nil, order...)
+		if err != nil {
+			t.Fatalf("error joining registries: %v", err)
+		}
+		b, err := json.Marshal(in, opts)
+		if err != nil {
+			t.Fatalf("error marshaling: %v", err)
+		}
+		if string(b) != want {
+			t.Errorf("got:\n%s\nwant:\n%s", b, want)
+		}
+
+		var out []pipeline
+		if err := json.Unmarshal(b, &out, opts); err != nil {
+			t.Fatalf("error unmarshaling: %v", err)
+		}
+		if len(out) != len(in) || out[0] != in[0] || out[1] != in[1] || out[2] != in[2] {
+			t.Errorf("got %+v, want %+v", out, in)
+		}
+	}
+}
+
+func Test_JoinRegistriesWrongPosition(t *testing.T) {
+	sources := oneof.NewRegistry[testSource]().
+		MustRegister("file", fileSource{})
+	sinks := oneof.NewRegistry[testSink]().
+		MustRegister("null", nullSink{}).
+		MustRegister("tee", tee{})
+
+	// tee implements testSource, but is only registered as a
+	// testSink, so the testSource registry could not decode it
+	for _, order := range [][]oneof.AnyRegistry{{sources, sinks}, {sinks, sources}} {
+		opts, err := oneof.JoinRegistries(nil, order...)
+		if err != nil {
+			t.Fatalf("error joining registries: %v", err)
+		}
+		_, err = json.Marshal(pipeline{Source: tee{}, Sink: nullSink{}}, opts)
+		if !errors.As(err, &oneof.ErrUnknownGoType{}) {
+			t.Errorf("got error %v, want ErrUnknownGoType", err)
+		}
+	}
+}
+
+func Test_JoinRegistriesConflict(t *testing.T) {
+	sources := oneof.NewRegistry[testSource]().
+		MustRegister("pipe", pipe{})
+	sinks := oneof.NewRegistry[testSink]().
+		MustRegister("pipe_sink", pipe{})
+
+	_, err := oneof.JoinRegistries(nil, sources, sinks)
+	if !errors.As(err, &oneof.ErrConflictingDiscriminators{}) {
+		t.Errorf("got error %v, want ErrConflictingDiscriminators", err)
+	}
+}
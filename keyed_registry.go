@@ -0,0 +1,163 @@
+package oneof
+
+import (
+	"fmt"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// JSONKey returns the discriminator value of an option which is identified by
+// the JSON encoding of k, such as a number or boolean, for use with a
+// [CustomValueWrapper] with JSONDiscriminator set:
+//
+//	r.MustRegister(oneof.MustJSONKey(17), &Heartbeat{})
+//
+// The result is the canonical JSON text of k (RFC 8785), so that, e.g., the
+// JSON numbers 17, 17.0 and 1.7e1 all match JSONKey(17).
+func JSONKey(k any) (string, error) {
+	b, err := json.Marshal(k, json.Deterministic(true))
+	if err != nil {
+		return "", fmt.Errorf("failed to encode key %v: %w", k, err)
+	}
+	v := jsontext.Value(b)
+	if err := v.Canonicalize(); err != nil {
+		return "", fmt.Errorf("failed to canonicalize key %v: %w", k, err)
+	}
+	return string(v), nil
+}
+
+// MustJSONKey is like [JSONKey], but panics if k cannot be encoded.
+func MustJSONKey(k any) string {
+	key, err := JSONKey(k)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// KeyedRegistry is a [Registry] whose discriminator values are Go values of
+// type K, such as numeric message type IDs:
+//
+//	r := oneof.NewKeyedRegistry[int, Message]().
+//	  MustRegister(17, &Heartbeat{}).
+//	  MustRegister(18, &Shutdown{})
+//	cw := oneof.CustomValueWrapper{
+//	  DiscriminatorKey:  "t",
+//	  NestedValueKey:    "d",
+//	  JSONDiscriminator: true,
+//	}
+//	opts := r.JSONOptions(&oneof.Config{WrapFunc: cw.Wrap})
+//
+// encodes options like {"t": 17, "d": {...}}.
+//
+// Values of K must encode to JSON strings, numbers or booleans (or arrays of
+// them, for composite discriminators). Options are stored in an underlying
+// [Registry] under their [JSONKey].
+type KeyedRegistry[K comparable, T any] struct {
+	r     *Registry[T]
+	byKey map[string]K // guarded by r.mu
+}
+
+// NewKeyedRegistry creates an empty [KeyedRegistry] for options of type T.
+func NewKeyedRegistry[K comparable, T any]() *KeyedRegistry[K, T] {
+	return &KeyedRegistry[K, T]{
+		r:     NewRegistry[T](),
+		byKey: map[string]K{},
+	}
+}
+
+// Register adds the option v to r under the discriminator value k.
+//
+// Register returns an error if k cannot be encoded as JSON, or for any reason
+// that [Registry.Register] would.
+func (r *KeyedRegistry[K, T]) Register(k K, v T) error {
+	key, err := JSONKey(k)
+	if err != nil {
+		return err
+	}
+	if err := r.r.Register(key, v); err != nil {
+		return err
+	}
+	r.r.mu.Lock()
+	r.byKey[key] = k
+	r.r.mu.Unlock()
+	return nil
+}
+
+// MustRegister is like [KeyedRegistry.Register], but panics if v cannot be
+// registered. It returns r, so that calls may be chained.
+func (r *KeyedRegistry[K, T]) MustRegister(k K, v T) *KeyedRegistry[K, T] {
+	if err := r.Register(k, v); err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// RegisterFunc adds an option to r under the discriminator value k, which is
+// created by calling fn. See [Registry.RegisterFunc].
+func (r *KeyedRegistry[K, T]) RegisterFunc(k K, fn func() T) error {
+	key, err := JSONKey(k)
+	if err != nil {
+		return err
+	}
+	if err := r.r.RegisterFunc(key, fn); err != nil {
+		return err
+	}
+	r.r.mu.Lock()
+	r.byKey[key] = k
+	r.r.mu.Unlock()
+	return nil
+}
+
+// MustRegisterFunc is like [KeyedRegistry.RegisterFunc], but panics if fn
+// cannot be registered. It returns r, so that calls may be chained.
+func (r *KeyedRegistry[K, T]) MustRegisterFunc(k K, fn func() T) *KeyedRegistry[K, T] {
+	if err := r.RegisterFunc(k, fn); err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Keys returns the discriminator values in r, in registration order.
+func (r *KeyedRegistry[K, T]) Keys() []K {
+	keys := r.r.Keys()
+
+	r.r.mu.Lock()
+	defer r.r.mu.Unlock()
+
+	ks := make([]K, len(keys))
+	for i, key := range keys {
+		ks[i] = r.byKey[key]
+	}
+	return ks
+}
+
+// Registry returns the underlying [Registry], whose keys are the [JSONKey]s of
+// the discriminator values in r. Use it with, e.g., [JoinRegistries].
+func (r *KeyedRegistry[K, T]) Registry() *Registry[T] {
+	return r.r
+}
+
+// Freeze prevents further registration in r.
+func (r *KeyedRegistry[K, T]) Freeze() {
+	r.r.Freeze()
+}
+
+// MarshalFunc freezes r and creates a [json.MarshalFuncV2] for the options in
+// r. See [MarshalFunc] for details.
+func (r *KeyedRegistry[K, T]) MarshalFunc(cfg *Config) *json.Marshalers {
+	return r.r.MarshalFunc(cfg)
+}
+
+// UnmarshalFunc freezes r and creates a [json.UnmarshalFuncV2] for the
+// options in r. See [UnmarshalFunc] for details.
+func (r *KeyedRegistry[K, T]) UnmarshalFunc(cfg *Config) *json.Unmarshalers {
+	return r.r.UnmarshalFunc(cfg)
+}
+
+// JSONOptions freezes r and returns [json.Options] which include both
+// [KeyedRegistry.MarshalFunc] and [KeyedRegistry.UnmarshalFunc].
+func (r *KeyedRegistry[K, T]) JSONOptions(cfg *Config) json.Options {
+	return r.r.JSONOptions(cfg)
+}
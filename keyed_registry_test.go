@@ -0,0 +1,134 @@
+package oneof_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/dhoelle/oneof"
+	"github.com/go-json-experiment/json"
+)
+
+type heartbeat struct {
+	Seq int `json:"seq"`
+}
+
+func (heartbeat) String() string { return "heartbeat" }
+
+type shutdown struct {
+	Reason string `json:"reason"`
+}
+
+func (*shutdown) String() string { return "shutdown" }
+
+func Test_KeyedRegistry(t *testing.T) {
+	r := oneof.NewKeyedRegistry[int, fmt.Stringer]().
+		MustRegister(17, heartbeat{}).
+		MustRegister(18, &shutdown{})
+	cw := oneof.CustomValueWrapper{
+		DiscriminatorKey:  "t",
+		NestedValueKey:    "d",
+		JSONDiscriminator: true,
+	}
+	opts := r.JSONOptions(&oneof.Config{WrapFunc: cw.Wrap})
+
+	if got, want := r.Keys(), []int{17, 18}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got keys %v, want %v", got, want)
+	}
+
+	in := []fmt.Stringer{heartbeat{Seq: 1}, &shutdown{Reason: "bye"}}
+	b, err := json.Marshal(in, opts)
+	if err != nil {
+		t.Fatalf("error marshaling: %v", err)
+	}
+	want := `[{"t":17,"d":{"seq":1}},{"t":18,"d":{"reason":"bye"}}]`
+	if string(b) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b, want)
+	}
+
+	var out []fmt.Stringer
+	if err := json.Unmarshal(b, &out, opts); err != nil {
+		t.Fatalf("error unmarshaling: %v", err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("got %#v, want %#v", out, in)
+	}
+
+	// Equal numbers match, however they are written
+	var s fmt.Stringer
+	if err := json.Unmarshal([]byte(`{"d":{"seq":2},"t":1.7e1}`), &s, opts); err != nil {
+		t.Fatalf("error unmarshaling: %v", err)
+	}
+	if want := (heartbeat{Seq: 2}); s != want {
+		t.Errorf("got %#v, want %#v", s, want)
+	}
+
+	// Unknown discriminators report the original JSON token
+	err = json.Unmarshal([]byte(`{"t":1.9e1}`), &s, opts)
+	var unknown oneof.ErrUnknownDiscriminatorValue
+	if !errors.As(err, &unknown) {
+		t.Fatalf("got error %v, want ErrUnknownDiscriminatorValue", err)
+	}
+	if got, want := string(unknown.Token()), "1.9e1"; got != want {
+		t.Errorf("got token %s, want %s", got, want)
+	}
+
+	// Other JSON kinds are not discriminators
+	if err := json.Unmarshal([]byte(`{"t":[17]}`), &s, opts); err == nil {
+		t.Errorf("got nil error, want error")
+	}
+}
+
+func Test_KeyedRegistryBoolAndComposite(t *testing.T) {
+	t.Run("bool", func(t *testing.T) {
+		r := oneof.NewKeyedRegistry[bool, fmt.Stringer]().
+			MustRegister(true, heartbeat{}).
+			MustRegister(false, &shutdown{})
+		cw := oneof.CustomValueWrapper{DiscriminatorKey: "alive", JSONDiscriminator: true, InlineObjects: true}
+		opts := r.JSONOptions(&oneof.Config{WrapFunc: cw.Wrap})
+
+		b, err := json.Marshal([]fmt.Stringer{heartbeat{Seq: 1}, &shutdown{}}, opts)
+		if err != nil {
+			t.Fatalf("error marshaling: %v", err)
+		}
+		want := `[{"alive":true,"seq":1},{"alive":false,"reason":""}]`
+		if string(b) != want {
+			t.Errorf("got:\n%s\nwant:\n%s", b, want)
+		}
+		var out []fmt.Stringer
+		if err := json.Unmarshal(b, &out, opts); err != nil {
+			t.Fatalf("error unmarshaling: %v", err)
+		}
+		if want := []fmt.Stringer{heartbeat{Seq: 1}, &shutdown{}}; !reflect.DeepEqual(out, want) {
+			t.Errorf("got %#v, want %#v", out, want)
+		}
+	})
+
+	t.Run("composite", func(t *testing.T) {
+		r := oneof.NewKeyedRegistry[[2]any, fmt.Stringer]().
+			MustRegister([2]any{1, "heartbeat"}, heartbeat{})
+		cw := oneof.CustomValueWrapper{
+			DiscriminatorPaths: []string{"/v", "/kind"},
+			JSONDiscriminator:  true,
+			InlineObjects:      true,
+		}
+		opts := r.JSONOptions(&oneof.Config{WrapFunc: cw.Wrap})
+
+		var in fmt.Stringer = heartbeat{Seq: 3}
+		b, err := json.Marshal(&in, opts)
+		if err != nil {
+			t.Fatalf("error marshaling: %v", err)
+		}
+		if want := `{"v":1,"kind":"heartbeat","seq":3}`; string(b) != want {
+			t.Errorf("got:\n%s\nwant:\n%s", b, want)
+		}
+		var out fmt.Stringer
+		if err := json.Unmarshal(b, &out, opts); err != nil {
+			t.Fatalf("error unmarshaling: %v", err)
+		}
+		if out != in {
+			t.Errorf("got %#v, want %#v", out, in)
+		}
+	})
+}
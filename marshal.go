@@ -30,17 +30,78 @@ type Config struct {
 	//
 	// If unset, defaults to [WrapNestObjects].
 	WrapFunc func(typ string, v jsontext.Value) WrappedValue
+
+	// By default, [MarshalFunc] intercepts every Go value that implements T,
+	// wherever it appears. For example, with T = fmt.Stringer, a
+	// time.Duration field would be wrapped (or, if time.Duration is not in
+	// the set of options, would fail to marshal with [ErrUnknownGoType]).
+	//
+	// If StaticTypeOnly is true, [MarshalFunc] only intercepts values whose
+	// static Go type is exactly T: struct fields, slice and array elements,
+	// map values and pointer targets declared as T. Values in other positions
+	// keep their default encoding.
+	//
+	// Note that a value passed directly to [json.Marshal] has no static type
+	// beyond its concrete type. To wrap a top-level value, pass a pointer to
+	// it (e.g., json.Marshal(&s), where s is declared as T).
+	//
+	// [UnmarshalFunc] always behaves this way, since Go values can only be
+	// unmarshaled into a position of type T if that position's static type
+	// is T.
+	StaticTypeOnly bool
+
+	// By default, options are matched to Go values ignoring one level of
+	// pointer indirection: if crypto.Hash is an option, then both
+	// crypto.Hash and *crypto.Hash values encode with its discriminator, and
+	// decode as crypto.Hash.
+	//
+	// If StrictTypes is true, options are matched by their exact Go type.
+	// For example, Config and *Config may be registered as distinct options,
+	// and a value decodes to exactly the form that was encoded. Marshaling a
+	// value whose exact type is not an option fails with [ErrUnknownGoType]
+	// (unless ReplaceMissingTypeFunc is set).
+	//
+	// Note that [json.Marshal] follows a top-level pointer before marshaling
+	// the value it points to, so json.Marshal(p) encodes *p. To encode the
+	// pointer form of a top-level value, pass a pointer to it (e.g.,
+	// json.Marshal(&s), where s is declared as T).
+	StrictTypes bool
+
+	// If ValuelessAsString is true, options whose default encoding is an
+	// empty JSON object (e.g., struct{}-based marker types) are encoded as a
+	// bare JSON string holding their discriminator, e.g., "round_robin",
+	// instead of being wrapped.
+	//
+	// [UnmarshalFunc] then also accepts a bare JSON string as the
+	// discriminator of an option with its zero value (or the result of its
+	// factory; see [Registry.RegisterFunc]). If T is any, only strings which
+	// match a registered discriminator are decoded this way; other strings
+	// decode by default. Marshaling a string which matches a registered
+	// discriminator in a position of type any is then an error, since it
+	// could not be told apart from the option.
+	ValuelessAsString bool
+
+	// If CompactSlices is true, slices of T ([]T) whose elements all share
+	// a discriminator are encoded as a single object, rather than by
+	// wrapping each element:
+	//
+	//	{"_type": "circle", "_values": [{"radius": 1}, {"radius": 2}]}
+	//
+	// Other slices are encoded as usual. [UnmarshalFunc] decodes slices of
+	// T from either form (regardless of CompactSlices).
+	//
+	// The compact form always uses the default "_type" key, so slices are
+	// only compacted if WrapFunc would write their elements' discriminator
+	// there too (e.g., nil, [WrapNested], [WrapInline] or a
+	// [CustomValueWrapper] without a DiscriminatorKey). With any other
+	// WrapFunc, each element is wrapped individually.
+	CompactSlices bool
 }
 
+// JSONOptions returns [json.Options] which include both [MarshalFunc] and
+// [UnmarshalFunc] for the provided options.
 func JSONOptions[T any](opts map[string]T, cfg *Config) json.Options {
-	return json.JoinOptions(
-		json.WithMarshalers(
-			MarshalFunc(opts, cfg),
-		),
-		json.WithUnmarshalers(
-			UnmarshalFunc(opts, cfg),
-		),
-	)
+	return registryFromMap(opts).JSONOptions(cfg)
 }
 
 // MarshalFunc creates a [json.MarshalFuncV2] which can intercept marshaling
@@ -55,11 +116,41 @@ func JSONOptions[T any](opts map[string]T, cfg *Config) json.Options {
 //     value
 //
 // Encoding behavior can be customized by providing a non-nil [Config].
+//
+// If several keys in opts map to the same Go type, MarshalFunc uses the first
+// of those keys in lexicographical order. Use a [Registry] to reject such
+// duplicates instead.
 func MarshalFunc[T any](opts map[string]T, cfg *Config) *json.Marshalers {
+	return registryFromMap(opts).MarshalFunc(cfg)
+}
+
+// MarshalFunc freezes r and creates a [json.MarshalFuncV2] for the options in
+// r. See [MarshalFunc] for details.
+func (r *Registry[T]) MarshalFunc(cfg *Config) *json.Marshalers {
+	r.Freeze()
+
 	if cfg == nil {
 		cfg = &Config{}
 	}
 
+	return r.marshalFunc(cfg, nil)
+}
+
+// marshalFunc creates a [json.MarshalFuncV2] for values of type T.
+//
+// If joined is non-nil, values whose static type is not T (and so which may
+// be options of another joined registry; see [JoinRegistries]) find their
+// discriminator values in joined. Values whose static type is T must be
+// options of r.
+func (r *Registry[T]) marshalFunc(cfg *Config, joined *typeIndex) *json.Marshalers {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+
+	// Index the options by Go type up front, rather than
+	// searching them for every marshaled value
+	types := r.typeIndex(cfg.StrictTypes)
+
 	wrapFunc := cfg.WrapFunc
 	if wrapFunc == nil {
 		wrapFunc = WrapNested
@@ -67,8 +158,6 @@ func MarshalFunc[T any](opts map[string]T, cfg *Config) *json.Marshalers {
 
 	replaceMissingTypeFunc := cfg.ReplaceMissingTypeFunc
 
-	// Hack:
-	//
 	// Our strategy for generically encoding a Go type into
 	// a JSON representation that includes the type is to:
 	//
@@ -85,39 +174,14 @@ func MarshalFunc[T any](opts map[string]T, cfg *Config) *json.Marshalers {
 	// for any interfaces which the Go type implements.
 	//
 	// This produces, by default, an infinite loop between
-	// steps (1) and (2), where calling json.Marshal(t) will
-	// re-invoke our marshalFunc
-	//
-	// To avoid this recursion, we set the skipNext toggle
-	// below whenever we want a "default" marshaling of T. If
-	// our MarshalFunc sees skipNext = true, it un-toggles
-	// skipNext and returns [json.SkipFunc], which instructs
-	// [json.Marshal] to skip our MarshalFunc.
-	skipNext := false
-	skipNextPtr := &skipNext
-
-	marshalFunc := func(enc *jsontext.Encoder, t T, jsonopts json.Options) error {
-		// If skipNextPtr is on, toggle it off and skip this
-		// custom marshal function. `t` will be encoded according
-		// to subsequent encoding rules; including the default
-		// encoding if no other rules preempt it.
-		// See [json.Marshal].
-		if *skipNextPtr {
-			*skipNextPtr = false
-			return json.SkipFunc
-		}
-
-		// If T is an interface that captures *jsontext.Value
-		// (e.g., fmt.Stringer), then our marshal func will
-		// intercept attempts to marshal jsontext.Values.
-		// We don't want to do that.
-		if _, ok := any(t).(*jsontext.Value); ok {
-			return json.SkipFunc
-		}
-
+	// steps (1) and (2), where marshaling t will re-invoke
+	// our marshalFunc. [marshalDefault] performs step (2)
+	// with an encoder that our marshalFunc recognizes, and
+	// skips. See guard.go for details.
+	wrap := func(enc *jsontext.Encoder, t T, types typeIndex, jsonopts json.Options) error {
 		// Determine the discriminator value that we should
 		// use for things of type `T`
-		discriminatorValue, ok := discriminatorValueFor(t, opts)
+		discriminatorValue, ok := types.keyFor(t)
 		if !ok {
 			if replaceMissingTypeFunc == nil {
 				return ErrUnknownGoType{typ: fmt.Sprintf("%T", t)}
@@ -125,22 +189,156 @@ func MarshalFunc[T any](opts map[string]T, cfg *Config) *json.Marshalers {
 			discriminatorValue = replaceMissingTypeFunc(t)
 		}
 
+		// Write the shorthand form of t, if it has one
+		if v, ok, err := r.formatShorthand(discriminatorValue, t); err != nil {
+			return err
+		} else if ok {
+			return enc.WriteValue(v)
+		}
+
 		// Marshal t by itself
-		*skipNextPtr = true // avoid recursion in Marshal below
-		b, err := json.Marshal(t, jsonopts)
+		var wrapErr error
+		err := marshalDefaultFrom(enc, wrapOwner, t, jsonopts, func(jv jsontext.Value) error {
+			if cfg.ValuelessAsString && isEmptyObject(jv) {
+				wrapErr = enc.WriteToken(jsontext.String(discriminatorValue))
+				return nil
+			}
+
+			// Wrap the marshal'ed value with the type
+			w := wrapFunc(discriminatorValue, jv)
+
+			// ...along with any JSON data kept from
+			// decoding it (see [SiblingKeeper])
+			if k, ok := any(t).(interface{ OneofSiblings() jsontext.Value }); ok {
+				if sw, ok := w.(siblingWrappedValue); ok {
+					w = sw.withSiblings(k.OneofSiblings())
+				}
+			}
+
+			// Finally, marshal the wrapper
+			wrapErr = json.MarshalEncode(enc, w, jsonopts)
+			return nil
+		})
 		if err != nil {
 			return fmt.Errorf("failed to marshal t: %w", err)
 		}
-		jv := jsontext.Value(b)
+		return wrapErr
+	}
+
+	// Collections of T have marshal funcs of their own
+	collectionFuncs := []*json.Marshalers{r.byTypeMarshalFunc(types, replaceMissingTypeFunc)}
+	if cfg.CompactSlices {
+		collectionFuncs = append(collectionFuncs, r.compactSliceMarshalFunc(types, wrapFunc))
+	}
+
+	// Every Go value implements the empty interface, so if T
+	// is any, only values whose static type is any can be
+	// intercepted, and values of unregistered types keep
+	// their default encoding
+	anyT := isEmptyInterface[T]()
+
+	// A marshal func for *T is only called for values whose
+	// static type is T (see [json.MarshalFuncV2]). Those
+	// values are never re-encountered while producing their
+	// own default encoding, whose static type is the concrete
+	// type of the value.
+	staticFunc := func(enc *jsontext.Encoder, ptr *T, jsonopts json.Options) error {
+		if any(*ptr) == nil {
+			return enc.WriteToken(jsontext.Null)
+		}
+		if anyT && replaceMissingTypeFunc == nil {
+			if _, ok := types.keyFor(*ptr); !ok {
+				if cfg.ValuelessAsString {
+					if err := r.checkBareString(*ptr); err != nil {
+						return err
+					}
+				}
+				return json.SkipFunc
+			}
+		}
+		return wrap(enc, *ptr, types, jsonopts)
+	}
+
+	if cfg.StaticTypeOnly || anyT {
+		return json.NewMarshalers(append(collectionFuncs, json.MarshalFuncV2(staticFunc))...)
+	}
+
+	// Values of type T which are also options of another
+	// joined registry are only wrapped with discriminators
+	// from that registry in positions whose static type is
+	// not T. In a position of type T, a value which is not
+	// an option of r could not be decoded by r.
+	dynamicTypes := types
+	if joined != nil || cfg.StrictTypes {
+		collectionFuncs = append(collectionFuncs, json.MarshalFuncV2(staticFunc))
+	}
+	if joined != nil {
+		dynamicTypes = *joined
+	}
+
+	if cfg.StrictTypes {
+		return json.NewMarshalers(append(collectionFuncs, r.strictMarshalFunc(dynamicTypes, wrap))...)
+	}
+
+	marshalFunc := func(enc *jsontext.Encoder, t T, jsonopts json.Options) error {
+		// If enc is producing the default encoding of t, skip
+		// this custom marshal function. `t` will be encoded
+		// according to subsequent encoding rules; including the
+		// default encoding if no other rules preempt it.
+		// See [json.Marshal].
+		if skipEncode(enc, wrapOwner) {
+			return json.SkipFunc
+		}
 
-		// Wrap the marshal'ed value with the type
-		w := wrapFunc(discriminatorValue, jv)
+		// If T is an interface that captures *jsontext.Value
+		// (e.g., fmt.Stringer), then our marshal func will
+		// intercept attempts to marshal jsontext.Values.
+		// We don't want to do that.
+		if _, ok := any(t).(*jsontext.Value); ok {
+			return json.SkipFunc
+		}
 
-		// Finally, marshal the wrapper
-		return json.MarshalEncode(enc, w, jsonopts)
+		return wrap(enc, t, dynamicTypes, jsonopts)
 	}
 
-	return json.MarshalFuncV2(marshalFunc)
+	return json.NewMarshalers(append(collectionFuncs, json.MarshalFuncV2(marshalFunc))...)
+}
+
+// strictMarshalFunc creates a [json.MarshalFuncV2] which, like the marshal
+// func for T, intercepts values that implement T wherever they appear, but
+// which calls wrap with the exact form of each value (see
+// [Config.StrictTypes]).
+//
+// A marshal func for T is handed a pointer to every value that implements T,
+// so it sees both Config and *Config values as *Config. A marshal func for
+// any is instead handed a pointer to every value, including pointers: a
+// *Config value is seen as **Config, before its pointer is followed.
+func (r *Registry[T]) strictMarshalFunc(types typeIndex, wrap func(*jsontext.Encoder, T, typeIndex, json.Options) error) *json.Marshalers {
+	typeT := reflect.TypeOf((*T)(nil)).Elem()
+	jsonValueT := reflect.TypeOf(jsontext.Value(nil))
+
+	return json.MarshalFuncV2(func(enc *jsontext.Encoder, v any, jsonopts json.Options) error {
+		if skipEncode(enc, wrapOwner) {
+			return json.SkipFunc
+		}
+
+		// v is a pointer to the value being marshaled
+		rv := reflect.ValueOf(v).Elem()
+		switch {
+		case rv.Kind() == reflect.Interface:
+			// Interface values are marshaled again by their
+			// concrete type
+			return json.SkipFunc
+		case rv.Kind() == reflect.Ptr && rv.IsNil():
+			return json.SkipFunc
+		case rv.Type() == jsonValueT || rv.Type() == reflect.PointerTo(jsonValueT):
+			// See the marshal func for T
+			return json.SkipFunc
+		case !rv.Type().Implements(typeT):
+			return json.SkipFunc
+		}
+		return wrap(enc, rv.Interface().(T), types, jsonopts)
+	})
 }
 
 // UnmarshalFunc creates a [json.UnmarshalFuncV2] which will intercept
@@ -150,6 +348,14 @@ func MarshalFunc[T any](opts map[string]T, cfg *Config) *json.Marshalers {
 // the JSON type discriminator, then decodes the remaining JSON according to the
 // default JSON encoding of T.
 func UnmarshalFunc[T any](opts map[string]T, cfg *Config) *json.Unmarshalers {
+	return registryFromMap(opts).UnmarshalFunc(cfg)
+}
+
+// UnmarshalFunc freezes r and creates a [json.UnmarshalFuncV2] for the options
+// in r. See [UnmarshalFunc] for details.
+func (r *Registry[T]) UnmarshalFunc(cfg *Config) *json.Unmarshalers {
+	r.Freeze()
+
 	if cfg == nil {
 		cfg = &Config{}
 	}
@@ -159,8 +365,6 @@ func UnmarshalFunc[T any](opts map[string]T, cfg *Config) *json.Unmarshalers {
 		wrapFunc = WrapNested
 	}
 
-	// Hack:
-	//
 	// Our strategy for generically decoding JSON into a Go
 	// type that implements T is to:
 	//
@@ -168,94 +372,269 @@ func UnmarshalFunc[T any](opts map[string]T, cfg *Config) *json.Unmarshalers {
 	//  2. Unmarshal the JSON into a type wrapper, which
 	//     includes type information as well as the JSON
 	//     that we should use to decode into T.
-	//  3. Create a new T based on the discriminator value
-	//     found in (2)
-	//  4. Unmarshal the "remainder" from (2) into T.
+	//  3. Create a new value based on the discriminator
+	//     value found in (2)
+	//  4. Unmarshal the "remainder" from (2) into the new
+	//     value.
 	//
-	// However, when unmarshaling a Go type,
-	// github.com/go-json-experiment/json looks for marshalers
-	// that are registered either for that concrete type, or
-	// for any interfaces which the Go type implements.
+	// Step (4) decodes into the option's concrete type, so
+	// it only re-invokes our unmarshalFunc if that type is T
+	// itself. [unmarshalDefault] performs step (4) with a
+	// decoder that our unmarshalFunc recognizes, and skips.
+	// See guard.go for details.
 	//
-	// This produces, by default, an infinite loop between
-	// steps (1) and (4), where calling json.Marshal(t) will
-	// re-invoke our unmarshalFunc
-	//
-	// To avoid this recursion, we set the skipNext toggle
-	// below whenever we want a "default" unmarshaling of T.
-	// If our UnmarshalFunc sees skipNext = true, it un-toggles
-	// skipNext and returns [json.SkipFunc], which instructs
-	// [json.Unmarshal] to skip our UnmarshalFunc.
-	skipNext := false
-	skipNextPtr := &skipNext
+	// If T is any, JSON values which are not wrapped (e.g.,
+	// primitives, and arrays and objects without a registered
+	// discriminator) are decoded with default behavior.
+	anyT := isEmptyInterface[T]()
 
 	unmarshalFunc := func(dec *jsontext.Decoder, ptr *T, jsonopts json.Options) error {
-		// If skipNextPtr is on, toggle it off and skip this
-		// custom unmarshal function. `t` will be decoded according
-		// to subsequent decoding rules; including the default
-		// encoding if no other rules preempt it.
+		// If dec is producing the default decoding of a value,
+		// skip this custom unmarshal function. `t` will be
+		// decoded according to subsequent decoding rules;
+		// including the default encoding if no other rules
+		// preempt it.
 		// See [json.Unmarshal].
-		if *skipNextPtr {
-			*skipNextPtr = false
+		if skipDecode(dec, wrapOwner) {
 			return json.SkipFunc
 		}
 
+		// A JSON null decodes to the zero value of T (e.g., a
+		// nil interface), just like it would by default
+		if dec.PeekKind() == 'n' {
+			if _, err := dec.ReadToken(); err != nil {
+				return err
+			}
+			var zero T
+			*ptr = zero
+			return nil
+		}
+
+		// A bare string may be the discriminator of an option
+		// with no value, and a string or number may be the
+		// shorthand form of an option
+		k := dec.PeekKind()
+		bare := cfg.ValuelessAsString && k == '"'
+		if bare || ((k == '"' || k == '0') && len(r.shorthands) > 0) {
+			raw, err := dec.ReadValue()
+			if err != nil {
+				return err
+			}
+			raw = raw.Clone()
+
+			var typ string
+			if bare {
+				if err := json.Unmarshal(raw, &typ); err != nil {
+					return err
+				}
+				if _, ok := r.lookupKey(typ); ok {
+					return r.decodeOption(ptr, typ, nil, nil, jsonopts)
+				}
+			}
+			if t, ok, err := r.parseShorthand(raw); err != nil {
+				return err
+			} else if ok {
+				*ptr = t
+				return nil
+			}
+
+			switch {
+			case anyT:
+				return decodeDefault(dec, ptr, raw, jsonopts)
+			case bare:
+				return ErrUnknownDiscriminatorValue{v: typ}
+			default:
+				return fmt.Errorf("no shorthand form matches %s", raw)
+			}
+		}
+
 		// We expect the JSON for this type to be wrapped in a
 		// way that tells us what type of T we should decode into.
 		//
 		// So first, decode the input into our wrapper type.
 		w := wrapFunc("", nil)
-		if err := json.UnmarshalDecode(dec, &w, jsonopts); err != nil {
+		if iw, ok := w.(invalidWrappedValue); ok {
+			// Don't mistake a misconfigured wrapper for a
+			// value which is not wrapped (if T is any)
+			return iw.err
+		}
+		mw, isMember := w.(memberWrappedValue)
+		var raw jsontext.Value // if T is any, the undecoded value
+		if anyT {
+			// Wrappers which are always objects (unlike, e.g.,
+			// [WrapTuple]) don't match arrays
+			if k := dec.PeekKind(); k != '{' && (k != '[' || isMember) {
+				return json.SkipFunc
+			}
+			var err error
+			if raw, err = dec.ReadValue(); err != nil {
+				return err
+			}
+			// Objects without the discriminator member are
+			// not wrapped, so don't decode (and scan nested
+			// values for) the wrapper
+			missing := isMember && !hasMember(raw, mw.discriminatorMember())
+			if missing || json.Unmarshal(raw, &w, jsonopts) != nil || w.Type() == "" {
+				// Not a wrapped value: decode it by default
+				return decodeDefault(dec, ptr, raw, jsonopts)
+			}
+		} else if err := json.UnmarshalDecode(dec, &w, jsonopts); err != nil {
 			return fmt.Errorf("failed to decode to type wrapper: %w", err)
 		}
 
-		// ...then, extract the type and use it to select a T
-		// from our options
-		opt, ok := opts[w.Type()]
-		if !ok {
-			return ErrUnknownDiscriminatorValue{v: w.Type()}
+		if _, ok := r.lookupKey(w.Type()); !ok {
+			if anyT && !isMember {
+				// Wrappers without a discriminator member
+				// (e.g., [WrapExternal]) also match values
+				// which are not wrapped at all
+				return decodeDefault(dec, ptr, raw, jsonopts)
+			}
+			err := ErrUnknownDiscriminatorValue{v: w.Type()}
+			if tw, ok := w.(interface{ token() jsontext.Value }); ok {
+				err.token = tw.token()
+			}
+			return err
 		}
 
-		// ...then, unmarshal the remainder into the selected
-		// option
-		optPtr := &opt
-		v := w.Value()
+		var siblings jsontext.Value
+		if sw, ok := w.(siblingWrappedValue); ok {
+			siblings = sw.siblings()
+		}
+		var hook func(any) error
+		if hw, ok := w.(decodeHookWrappedValue); ok {
+			hook = hw.afterDecode
+		}
+		return r.decodeOptionWith(ptr, w.Type(), w.Value(), siblings, hook, jsonopts)
+	}
+	return json.NewUnmarshalers(
+		r.byTypeUnmarshalFunc(),
+		r.compactSliceUnmarshalFunc(),
+		json.UnmarshalFuncV2(unmarshalFunc),
+	)
+}
+
+// decodeDefault decodes v, read from dec, by default into a new value of type
+// any (and so T, if T is any), and stores the result in ptr.
+func decodeDefault[T any](dec *jsontext.Decoder, ptr *T, v jsontext.Value, jsonopts json.Options) error {
+	var dst any
+	if err := unmarshalDefaultFrom(dec, wrapOwner, v, &dst, jsonopts); err != nil {
+		return err
+	}
+	*ptr = dst.(T)
+	return nil
+}
 
-		if len(v) != 0 {
-			*skipNextPtr = true // avoid recursion in Unmarshal below
-			if err := json.Unmarshal(v, optPtr, jsonopts); err != nil {
-				return fmt.Errorf("failed to marshal value to option type %T: %w", opt, err)
-			}
+// decodeOption selects the option with discriminator typ, decodes v into a new
+// value of that option's type, and stores the result in ptr. If v is empty,
+// the new value is left as created. If siblings is non-empty, it is handed to
+// the new value if it is a [SiblingKeeper].
+func (r *Registry[T]) decodeOption(ptr *T, typ string, v, siblings jsontext.Value, jsonopts json.Options) error {
+	return r.decodeOptionWith(ptr, typ, v, siblings, nil, jsonopts)
+}
+
+// decodeOptionWith is like decodeOption, but also calls hook, if non-nil,
+// with a pointer to the new value (see [decodeHookWrappedValue]).
+func (r *Registry[T]) decodeOptionWith(ptr *T, typ string, v, siblings jsontext.Value, hook func(any) error, jsonopts json.Options) error {
+	// Use the type to select a T from our options
+	opt, ok := r.lookupKey(typ)
+	if !ok {
+		return ErrUnknownDiscriminatorValue{v: typ}
+	}
+
+	// ...then, create a new value of the selected option's
+	// type, either from its factory or as a new zero value.
+	// A registered option value is only a prototype:
+	// decoding into it directly would make every decoded
+	// pointer option alias the same underlying value.
+	dst, err := opt.newValue()
+	if err != nil {
+		return err
+	}
+
+	// ...then, unmarshal the remainder into the new value
+	if len(v) != 0 {
+		if err := unmarshalDefault(v, dst.target.Interface(), jsonopts); err != nil {
+			return fmt.Errorf("failed to marshal value to option type %v: %w", opt.typ, err)
 		}
+	}
+	if len(siblings) > 0 {
+		if k, ok := dst.target.Interface().(SiblingKeeper); ok {
+			k.SetOneofSiblings(siblings)
+		}
+	}
+	if hook != nil {
+		if err := hook(dst.target.Interface()); err != nil {
+			return err
+		}
+	}
 
-		*ptr = opt
+	*ptr = dst.result.Interface().(T)
+	return nil
+}
+
+// checkBareString returns an error if v is a string (which encodes by default
+// to a JSON string) holding the discriminator value of an option of r, since
+// it would decode as that option (see [Config.ValuelessAsString]).
+func (r *Registry[T]) checkBareString(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.String {
 		return nil
 	}
-	return json.UnmarshalFuncV2(unmarshalFunc)
+	if _, ok := r.lookupKey(rv.String()); ok {
+		return fmt.Errorf("cannot marshal string %q, which would unmarshal as the option with that discriminator value", rv.String())
+	}
+	return nil
 }
 
-// discriminatorValueFor returns the key of the first option in opts whose type,
-// according to [reflect.TypeOf], matches t.
-func discriminatorValueFor[T any](t T, opts map[string]T) (string, bool) {
-	typeT := reflect.TypeOf(t)
-	if typeT.Kind() == reflect.Ptr {
-		typeT = typeT.Elem()
+// isEmptyObject reports whether v is a JSON object with no members.
+func isEmptyObject(v jsontext.Value) bool {
+	if v.Kind() != '{' {
+		return false
 	}
-	var key string
-	for k, ot := range opts {
-		typeOT := reflect.TypeOf(ot)
-		if typeOT.Kind() == reflect.Ptr {
-			typeOT = typeOT.Elem()
-		}
-		if typeOT == typeT {
-			key = k
-			break
+	for _, c := range v[1:] {
+		switch c {
+		case ' ', '\t', '\n', '\r':
+		default:
+			return c == '}'
 		}
 	}
-	if key == "" {
-		return "", false // not found
+	return false
+}
+
+// isEmptyInterface reports whether T is an interface type with no methods,
+// such as any.
+func isEmptyInterface[T any]() bool {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	return typ.Kind() == reflect.Interface && typ.NumMethod() == 0
+}
+
+// newValue holds a newly allocated Go value created by [newValueOf].
+type newValue struct {
+	// target is a non-nil pointer which can be passed to
+	// [json.Unmarshal]
+	target reflect.Value
+
+	// result is the value that should be handed back to
+	// callers once target has been decoded into
+	result reflect.Value
+}
+
+// newValueOf allocates a new, zero value of the option type typ.
+//
+// If typ is a pointer type (e.g., *url.URL), the result is a new pointer to a
+// new zero value of the element type, and target is that same pointer. If
+// typ is not a pointer type (e.g., crypto.Hash), the result is a new zero
+// value of typ, and target points at it.
+func newValueOf(typ reflect.Type) (newValue, error) {
+	if typ == nil {
+		return newValue{}, fmt.Errorf("cannot create a value from a nil option")
 	}
-	return key, true // found
+	if typ.Kind() == reflect.Ptr {
+		p := reflect.New(typ.Elem())
+		return newValue{target: p, result: p}, nil
+	}
+	p := reflect.New(typ)
+	return newValue{target: p, result: p.Elem()}, nil
 }
 
 // WrappedValue is the interface implemented by types that can encode a Go type
@@ -266,6 +645,51 @@ type WrappedValue interface {
 	Value() jsontext.Value
 }
 
+// memberWrappedValue is implemented by [WrappedValue]s which are always JSON
+// objects with a member named discriminatorMember(), holding (or leading to)
+// the discriminator. JSON objects without that member can then be recognized
+// as unwrapped without decoding them.
+type memberWrappedValue interface {
+	WrappedValue
+	discriminatorMember() string
+}
+
+// hasMember reports whether the valid JSON object v may have a member named
+// name. Names with escape sequences are assumed to match.
+//
+// hasMember scans v directly, since it is called for every object decoded
+// into a value of type any.
+func hasMember(v jsontext.Value, name string) bool {
+	depth := 0
+	isName := false // whether the next string at depth 1 is a name
+	for i := 0; i < len(v); i++ {
+		switch v[i] {
+		case '{':
+			depth++
+			isName = depth == 1
+		case '[':
+			depth++
+		case '}', ']':
+			depth--
+		case ',':
+			isName = depth == 1
+		case '"':
+			start, escaped := i+1, false
+			for i++; i < len(v) && v[i] != '"'; i++ {
+				if v[i] == '\\' {
+					escaped = true
+					i++
+				}
+			}
+			if isName && (escaped || string(v[start:i]) == name) {
+				return true
+			}
+			isName = false
+		}
+	}
+	return false
+}
+
 // WrapNested nests the provided jsontext.Value underneath the "_value" key
 // within the wrapper object
 func WrapNested(typ string, v jsontext.Value) WrappedValue {
@@ -284,6 +708,7 @@ func (w alwaysNestWrappedValue) Type() string { return w.Typ }
 func (w alwaysNestWrappedValue) Value() jsontext.Value {
 	return w.NestedValue
 }
+func (w alwaysNestWrappedValue) discriminatorMember() string { return defaultTypeDiscriminatorKey }
 
 // WrapInline wraps a oneof value with default "inline" behavior, specifically:
 //
@@ -328,6 +753,9 @@ type inlineObjectsWrappedValue struct {
 }
 
 func (w inlineObjectsWrappedValue) Type() string { return w.Typ }
+func (w inlineObjectsWrappedValue) discriminatorMember() string {
+	return defaultTypeDiscriminatorKey
+}
 func (w inlineObjectsWrappedValue) Value() jsontext.Value {
 	if len(w.NestedValue) > 0 {
 		return w.NestedValue
@@ -1,12 +1,19 @@
 package oneof_test
 
 import (
+	"crypto"
+	"errors"
 	"fmt"
+	"net"
 	"net/url"
+	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/dhoelle/oneof"
 	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
 )
 
 func Test_UnmarshalEmptyObject(t *testing.T) {
@@ -33,3 +40,486 @@ func Test_UnmarshalEmptyObject(t *testing.T) {
 		t.Errorf("got != want")
 	}
 }
+
+func Test_UnmarshalFreshValues(t *testing.T) {
+	opts := map[string]fmt.Stringer{
+		"url.URL":     &url.URL{},
+		"crypto.Hash": crypto.Hash(0),
+	}
+	unmarshalOpts := json.WithUnmarshalers(oneof.UnmarshalFunc(opts, nil))
+
+	t.Run("slice of pointers", func(t *testing.T) {
+		in := []byte(`[
+			{"_type":"url.URL","_value":{"Host":"a.example.com"}},
+			{"_type":"url.URL","_value":{"Host":"b.example.com"}}
+		]`)
+		var got []fmt.Stringer
+		if err := json.Unmarshal(in, &got, unmarshalOpts); err != nil {
+			t.Fatalf("error unmarshaling: %v", err)
+		}
+		if len(got) != 2 {
+			t.Fatalf("got %d elements, want 2", len(got))
+		}
+		a, b := got[0].(*url.URL), got[1].(*url.URL)
+		if a == b {
+			t.Fatalf("elements alias the same *url.URL")
+		}
+		if a.Host != "a.example.com" || b.Host != "b.example.com" {
+			t.Errorf("got hosts %q, %q; want a.example.com, b.example.com", a.Host, b.Host)
+		}
+	})
+
+	t.Run("map of pointers", func(t *testing.T) {
+		in := []byte(`{
+			"a": {"_type":"url.URL","_value":{"Host":"a.example.com"}},
+			"b": {"_type":"url.URL","_value":{"Host":"b.example.com"}}
+		}`)
+		var got map[string]fmt.Stringer
+		if err := json.Unmarshal(in, &got, unmarshalOpts); err != nil {
+			t.Fatalf("error unmarshaling: %v", err)
+		}
+		a, b := got["a"].(*url.URL), got["b"].(*url.URL)
+		if a == b {
+			t.Fatalf("values alias the same *url.URL")
+		}
+		if a.Host != "a.example.com" || b.Host != "b.example.com" {
+			t.Errorf("got hosts %q, %q; want a.example.com, b.example.com", a.Host, b.Host)
+		}
+	})
+
+	t.Run("nested structs", func(t *testing.T) {
+		type inner struct {
+			S fmt.Stringer `json:"s"`
+		}
+		type outer struct {
+			S     fmt.Stringer `json:"s"`
+			Inner inner        `json:"inner"`
+		}
+		in := []byte(`{
+			"s": {"_type":"url.URL","_value":{"Host":"outer.example.com"}},
+			"inner": {"s": {"_type":"url.URL","_value":{"Host":"inner.example.com"}}}
+		}`)
+		var got outer
+		if err := json.Unmarshal(in, &got, unmarshalOpts); err != nil {
+			t.Fatalf("error unmarshaling: %v", err)
+		}
+		o, i := got.S.(*url.URL), got.Inner.S.(*url.URL)
+		if o == i {
+			t.Fatalf("fields alias the same *url.URL")
+		}
+		if o.Host != "outer.example.com" || i.Host != "inner.example.com" {
+			t.Errorf("got hosts %q, %q; want outer.example.com, inner.example.com", o.Host, i.Host)
+		}
+	})
+
+	t.Run("values", func(t *testing.T) {
+		in := []byte(`[
+			{"_type":"crypto.Hash","_value":5},
+			{"_type":"crypto.Hash","_value":7}
+		]`)
+		var got []fmt.Stringer
+		if err := json.Unmarshal(in, &got, unmarshalOpts); err != nil {
+			t.Fatalf("error unmarshaling: %v", err)
+		}
+		if got[0] != crypto.SHA256 || got[1] != crypto.SHA512 {
+			t.Errorf("got %v, want [%v %v]", got, crypto.SHA256, crypto.SHA512)
+		}
+	})
+
+	t.Run("options are not modified", func(t *testing.T) {
+		in := []byte(`{"_type":"url.URL","_value":{"Host":"a.example.com"}}`)
+		var got fmt.Stringer
+		if err := json.Unmarshal(in, &got, unmarshalOpts); err != nil {
+			t.Fatalf("error unmarshaling: %v", err)
+		}
+		if got == opts["url.URL"] {
+			t.Fatalf("got the option prototype")
+		}
+		if *opts["url.URL"].(*url.URL) != (url.URL{}) {
+			t.Errorf("option prototype was modified")
+		}
+	})
+}
+
+func Test_ConcurrentNestedRoundTrip(t *testing.T) {
+	opts := map[string]fmt.Stringer{
+		"literal":     LiteralStringer(""),
+		"join":        JoinStringer{},
+		"exclamation": ExclamationPointsStringer(0),
+	}
+	jsonOpts := oneof.JSONOptions(opts, nil)
+
+	// Build deeply nested values of the same type
+	newValue := func(depth int) (fmt.Stringer, string) {
+		var s fmt.Stringer = LiteralStringer("x")
+		for i := 0; i < depth; i++ {
+			s = JoinStringer{A: s, Separator: "-", B: ExclamationPointsStringer(i % 3)}
+		}
+		return s, s.String()
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				in, want := newValue(g + i)
+				b, err := json.Marshal(&in, jsonOpts)
+				if err != nil {
+					t.Errorf("error marshaling: %v", err)
+					return
+				}
+				var out fmt.Stringer
+				if err := json.Unmarshal(b, &out, jsonOpts); err != nil {
+					t.Errorf("error unmarshaling: %v", err)
+					return
+				}
+				if got := out.String(); got != want {
+					t.Errorf("got %q, want %q", got, want)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func Test_StaticTypeOnly(t *testing.T) {
+	type doc struct {
+		Stringer  fmt.Stringer            `json:"stringer"`
+		Stringers []fmt.Stringer          `json:"stringers"`
+		ByName    map[string]fmt.Stringer `json:"by_name"`
+		Nil       fmt.Stringer            `json:"nil"`
+		Timeout   time.Duration           `json:"timeout"`
+		Addr      net.IP                  `json:"addr"`
+	}
+	opts := map[string]fmt.Stringer{
+		"crypto.Hash": crypto.Hash(0),
+		"url.URL":     &url.URL{},
+	}
+	in := doc{
+		Stringer:  crypto.SHA256,
+		Stringers: []fmt.Stringer{crypto.MD5},
+		ByName:    map[string]fmt.Stringer{"h": crypto.SHA512},
+		Timeout:   time.Second,
+		Addr:      net.IPv4(127, 0, 0, 1),
+	}
+
+	// By default, the time.Duration and net.IP fields are intercepted
+	_, err := json.Marshal(in, oneof.JSONOptions(opts, nil))
+	if !errors.As(err, &oneof.ErrUnknownGoType{}) {
+		t.Fatalf("got error %v, want ErrUnknownGoType", err)
+	}
+
+	cfg := &oneof.Config{StaticTypeOnly: true}
+	jsonOpts := json.JoinOptions(oneof.JSONOptions(opts, cfg), json.Deterministic(true))
+	b, err := json.Marshal(in, jsonOpts)
+	if err != nil {
+		t.Fatalf("error marshaling: %v", err)
+	}
+	want := `{"stringer":{"_type":"crypto.Hash","_value":5},` +
+		`"stringers":[{"_type":"crypto.Hash","_value":2}],` +
+		`"by_name":{"h":{"_type":"crypto.Hash","_value":7}},` +
+		`"nil":null,"timeout":"1s","addr":"127.0.0.1"}`
+	if string(b) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b, want)
+	}
+
+	var out doc
+	if err := json.Unmarshal(b, &out, jsonOpts); err != nil {
+		t.Fatalf("error unmarshaling: %v", err)
+	}
+	if out.Stringer != crypto.SHA256 || out.ByName["h"] != crypto.SHA512 ||
+		out.Stringers[0] != crypto.MD5 || out.Nil != nil ||
+		out.Timeout != time.Second || !out.Addr.Equal(in.Addr) {
+		t.Errorf("round trip mismatch: got %+v", out)
+	}
+
+	// Top-level values are wrapped when passed by pointer
+	var s fmt.Stringer = crypto.SHA256
+	b, err = json.Marshal(&s, jsonOpts)
+	if err != nil {
+		t.Fatalf("error marshaling: %v", err)
+	}
+	if want := `{"_type":"crypto.Hash","_value":5}`; string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+}
+
+// strictConfig implements fmt.Stringer with a value receiver, so both
+// strictConfig and *strictConfig are fmt.Stringers
+type strictConfig struct {
+	Name string `json:"name"`
+}
+
+func (c strictConfig) String() string { return c.Name }
+
+func Test_StrictTypes(t *testing.T) {
+	r := oneof.NewRegistry[fmt.Stringer]().
+		MustRegister("config", strictConfig{}).
+		MustRegister("config_ptr", &strictConfig{})
+	opts := r.JSONOptions(&oneof.Config{StrictTypes: true})
+
+	in := []fmt.Stringer{strictConfig{Name: "a"}, &strictConfig{Name: "b"}}
+	b, err := json.Marshal(in, opts)
+	if err != nil {
+		t.Fatalf("error marshaling: %v", err)
+	}
+	want := `[{"_type":"config","_value":{"name":"a"}},{"_type":"config_ptr","_value":{"name":"b"}}]`
+	if string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+
+	var out []fmt.Stringer
+	if err := json.Unmarshal(b, &out, opts); err != nil {
+		t.Fatalf("error unmarshaling: %v", err)
+	}
+	if v, ok := out[0].(strictConfig); !ok || v.Name != "a" {
+		t.Errorf("got %#v, want strictConfig{Name: \"a\"}", out[0])
+	}
+	if v, ok := out[1].(*strictConfig); !ok || v.Name != "b" {
+		t.Errorf("got %#v, want &strictConfig{Name: \"b\"}", out[1])
+	}
+
+	// Without StrictTypes, both forms share the first matching option
+	b, err = json.Marshal(in, r.JSONOptions(nil))
+	if err != nil {
+		t.Fatalf("error marshaling: %v", err)
+	}
+	want = `[{"_type":"config","_value":{"name":"a"}},{"_type":"config","_value":{"name":"b"}}]`
+	if string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+
+	// Values whose static type is not T are wrapped with
+	// the discriminator of their exact form, too
+	var top fmt.Stringer = strictConfig{Name: "c"}
+	b, err = json.Marshal(top, opts)
+	if err != nil {
+		t.Fatalf("error marshaling: %v", err)
+	}
+	if want := `{"_type":"config","_value":{"name":"c"}}`; string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+	var topOut fmt.Stringer
+	if err := json.Unmarshal(b, &topOut, opts); err != nil {
+		t.Fatalf("error unmarshaling: %v", err)
+	}
+	if topOut != top {
+		t.Errorf("got %#v, want %#v", topOut, top)
+	}
+	b, err = json.Marshal(map[string]any{"a": strictConfig{Name: "e"}, "b": &strictConfig{Name: "f"}}, opts, json.Deterministic(true))
+	if err != nil {
+		t.Fatalf("error marshaling: %v", err)
+	}
+	want = `{"a":{"_type":"config","_value":{"name":"e"}},"b":{"_type":"config_ptr","_value":{"name":"f"}}}`
+	if string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+
+	// A form that is not registered is an error
+	valueOnly := oneof.NewRegistry[fmt.Stringer]().
+		MustRegister("config", strictConfig{})
+	valueOnlyOpts := valueOnly.JSONOptions(&oneof.Config{StrictTypes: true})
+	_, err = json.Marshal(in, valueOnlyOpts)
+	if !errors.As(err, &oneof.ErrUnknownGoType{}) {
+		t.Errorf("got error %v, want ErrUnknownGoType", err)
+	}
+	_, err = json.Marshal(map[string]any{"b": &strictConfig{Name: "g"}}, valueOnlyOpts)
+	if !errors.As(err, &oneof.ErrUnknownGoType{}) {
+		t.Errorf("got error %v, want ErrUnknownGoType", err)
+	}
+}
+
+type anyCircle struct {
+	Radius float64 `json:"radius"`
+}
+
+type anySquare struct {
+	Side  float64 `json:"side"`
+	Label any     `json:"label"`
+}
+
+func Test_AnyOptions(t *testing.T) {
+	r := oneof.NewRegistry[any]().
+		MustRegister("circle", anyCircle{}).
+		MustRegister("square", &anySquare{})
+	opts := json.JoinOptions(r.JSONOptions(nil), json.Deterministic(true))
+
+	in := map[string]any{
+		"shape": anyCircle{Radius: 1},
+		"count": 3,
+		"name":  "shapes",
+		"ok":    true,
+		"tags":  []any{"a", &anySquare{Side: 2, Label: anyCircle{Radius: 3}}},
+		"meta":  map[string]any{"x": 1.5, "y": nil},
+		"raw":   []int{1, 2},
+	}
+	b, err := json.Marshal(in, opts)
+	if err != nil {
+		t.Fatalf("error marshaling: %v", err)
+	}
+	want := `{"count":3,` +
+		`"meta":{"x":1.5,"y":null},` +
+		`"name":"shapes",` +
+		`"ok":true,` +
+		`"raw":[1,2],` +
+		`"shape":{"_type":"circle","_value":{"radius":1}},` +
+		`"tags":["a",{"_type":"square","_value":{"side":2,"label":{"_type":"circle","_value":{"radius":3}}}}]}`
+	if string(b) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b, want)
+	}
+
+	var out map[string]any
+	if err := json.Unmarshal(b, &out, opts); err != nil {
+		t.Fatalf("error unmarshaling: %v", err)
+	}
+	wantOut := map[string]any{
+		"shape": anyCircle{Radius: 1},
+		"count": 3.0,
+		"name":  "shapes",
+		"ok":    true,
+		"tags":  []any{"a", &anySquare{Side: 2, Label: anyCircle{Radius: 3}}},
+		"meta":  map[string]any{"x": 1.5, "y": nil},
+		"raw":   []any{1.0, 2.0},
+	}
+	if !reflect.DeepEqual(out, wantOut) {
+		t.Errorf("got %#v, want %#v", out, wantOut)
+	}
+
+	// A discriminator which is not registered is an error
+	var v any
+	err = json.Unmarshal([]byte(`{"_type":"triangle"}`), &v, opts)
+	if !errors.As(err, &oneof.ErrUnknownDiscriminatorValue{}) {
+		t.Errorf("got error %v, want ErrUnknownDiscriminatorValue", err)
+	}
+
+	// Objects are only wrapped if they have a discriminator
+	// member of their own (see also Benchmark_UnmarshalAnyDepth)
+	kindOpts := r.JSONOptions(&oneof.Config{WrapFunc: oneof.CustomValueWrapper{DiscriminatorKey: "kind"}.Wrap})
+	tests := map[string]any{
+		`{"x":"kind","y":[{"kind":1}]}`:            map[string]any{"x": "kind", "y": []any{map[string]any{"kind": 1.0}}},
+		`{"a":{"kind":"circle","radius":1}}`:       map[string]any{"a": anyCircle{Radius: 1}},
+		`{"x":{"y":1},"kind":"circle","radius":2}`: anyCircle{Radius: 2},
+		`{"x":{"y":1},"ki\u006ed":"circle"}`:       anyCircle{},
+	}
+	for in, want := range tests {
+		var got any
+		if err := json.Unmarshal([]byte(in), &got, kindOpts); err != nil {
+			t.Errorf("%s: error unmarshaling: %v", in, err)
+		} else if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %#v, want %#v", in, got, want)
+		}
+	}
+
+	// Without a discriminator member, values are only
+	// wrapped if their discriminator is registered
+	for name, wrapFunc := range map[string]func(string, jsontext.Value) oneof.WrappedValue{
+		"external": oneof.WrapExternal,
+		"tuple":    oneof.WrapTuple,
+	} {
+		opts := json.JoinOptions(r.JSONOptions(&oneof.Config{WrapFunc: wrapFunc}), json.Deterministic(true))
+		in := map[string]any{
+			"payload": map[string]any{"a": 1.0},
+			"pair":    []any{"a", 1.0},
+			"one":     []any{"b"},
+			"shape":   anyCircle{Radius: 1},
+			"shapes":  []any{&anySquare{Side: 2}},
+		}
+		b, err := json.Marshal(in, opts)
+		if err != nil {
+			t.Fatalf("%s: error marshaling: %v", name, err)
+		}
+		var out any
+		if err := json.Unmarshal(b, &out, opts); err != nil {
+			t.Fatalf("%s: error unmarshaling %s: %v", name, b, err)
+		}
+		if !reflect.DeepEqual(out, any(in)) {
+			t.Errorf("%s: got %#v, want %#v", name, out, in)
+		}
+	}
+}
+
+type roundRobin struct{}
+
+func (roundRobin) String() string { return "round robin" }
+
+type weighted struct {
+	Weights []int `json:"weights,omitempty"`
+}
+
+func (weighted) String() string { return "weighted" }
+
+func Test_ValuelessAsString(t *testing.T) {
+	r := oneof.NewRegistry[fmt.Stringer]().
+		MustRegister("round_robin", roundRobin{}).
+		MustRegister("weighted", &weighted{}).
+		MustRegister("literal", LiteralStringer(""))
+	opts := r.JSONOptions(&oneof.Config{ValuelessAsString: true})
+
+	in := []fmt.Stringer{
+		roundRobin{},
+		&weighted{},
+		&weighted{Weights: []int{1, 2}},
+		LiteralStringer("round_robin"),
+	}
+	b, err := json.Marshal(in, opts)
+	if err != nil {
+		t.Fatalf("error marshaling: %v", err)
+	}
+	want := `["round_robin","weighted",` +
+		`{"_type":"weighted","_value":{"weights":[1,2]}},` +
+		`{"_type":"literal","_value":"round_robin"}]`
+	if string(b) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b, want)
+	}
+
+	var out []fmt.Stringer
+	if err := json.Unmarshal(b, &out, opts); err != nil {
+		t.Fatalf("error unmarshaling: %v", err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("got %#v, want %#v", out, in)
+	}
+
+	// An unknown bare string is an error
+	var s fmt.Stringer
+	err = json.Unmarshal([]byte(`"random"`), &s, opts)
+	if !errors.As(err, &oneof.ErrUnknownDiscriminatorValue{}) {
+		t.Errorf("got error %v, want ErrUnknownDiscriminatorValue", err)
+	}
+
+	// Without ValuelessAsString, a bare string is not accepted
+	if err := json.Unmarshal([]byte(`"round_robin"`), &s, r.JSONOptions(nil)); err == nil {
+		t.Errorf("got nil error, want error")
+	}
+
+	// If T is any, strings which are not discriminators decode
+	// as strings
+	anyR := oneof.NewRegistry[any]().MustRegister("round_robin", roundRobin{})
+	var got map[string]any
+	err = json.Unmarshal([]byte(`{"a":"round_robin","b":"random"}`), &got, anyR.JSONOptions(&oneof.Config{ValuelessAsString: true}))
+	if err != nil {
+		t.Fatalf("error unmarshaling: %v", err)
+	}
+	if want := map[string]any{"a": roundRobin{}, "b": "random"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+
+	// ...and round trip
+	b, err = json.Marshal(got, anyR.JSONOptions(&oneof.Config{ValuelessAsString: true}), json.Deterministic(true))
+	if err != nil {
+		t.Fatalf("error marshaling: %v", err)
+	}
+	if want := `{"a":"round_robin","b":"random"}`; string(b) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b, want)
+	}
+
+	// A string which matches a discriminator would not round
+	// trip, so it cannot be marshaled
+	_, err = json.Marshal(map[string]any{"name": "round_robin"}, anyR.JSONOptions(&oneof.Config{ValuelessAsString: true}))
+	if err == nil {
+		t.Errorf("got nil error, want error")
+	}
+}
@@ -0,0 +1,5 @@
+//go:build !race
+
+package oneof
+
+const raceEnabled = false
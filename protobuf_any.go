@@ -0,0 +1,174 @@
+package oneof
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// The default type URL prefix of google.protobuf.Any messages
+const defaultTypeURLPrefix = "type.googleapis.com/"
+
+// protobufWellKnownTypes are the full names of the well-known protobuf types
+// with a special JSON mapping, whose values are nested under "value" in the
+// JSON mapping of google.protobuf.Any.
+var protobufWellKnownTypes = map[string]bool{
+	"google.protobuf.Any":         true,
+	"google.protobuf.BoolValue":   true,
+	"google.protobuf.BytesValue":  true,
+	"google.protobuf.DoubleValue": true,
+	"google.protobuf.Duration":    true,
+	"google.protobuf.Empty":       true,
+	"google.protobuf.FieldMask":   true,
+	"google.protobuf.FloatValue":  true,
+	"google.protobuf.Int32Value":  true,
+	"google.protobuf.Int64Value":  true,
+	"google.protobuf.ListValue":   true,
+	"google.protobuf.StringValue": true,
+	"google.protobuf.Struct":      true,
+	"google.protobuf.Timestamp":   true,
+	"google.protobuf.UInt32Value": true,
+	"google.protobuf.UInt64Value": true,
+	"google.protobuf.Value":       true,
+}
+
+// ProtoAnyWrapper builds a WrapFunc which follows the JSON mapping of
+// google.protobuf.Any, as produced by protojson and gRPC-gateway, with
+// options registered under the full names of protobuf messages:
+//
+//	r.MustRegister("acme.storage.v1.S3Config", &S3Config{})
+//	cfg := &oneof.Config{
+//	  WrapFunc: oneof.ProtoAnyWrapper{}.Wrap,
+//	}
+//
+// produces JSON like:
+//
+//	{
+//	  "@type": "type.googleapis.com/acme.storage.v1.S3Config",
+//	  "bucket": "x"
+//	}
+//
+// Values of the well-known types with a special JSON mapping (such as
+// google.protobuf.Duration, google.protobuf.Timestamp and the wrapper types)
+// are instead nested under "value":
+//
+//	{
+//	  "@type": "type.googleapis.com/google.protobuf.Duration",
+//	  "value": "1.5s"
+//	}
+//
+// When unmarshaling, the type URL may have any prefix: only its last path
+// segment is resolved against the registry. ProtoAnyWrapper does not depend
+// on protobuf: option types are plain Go types whose default JSON encoding
+// matches the protobuf JSON mapping of their message.
+type ProtoAnyWrapper struct {
+	// TypeURLPrefix is written before the full message name when
+	// marshaling. If empty, defaults to "type.googleapis.com/".
+	TypeURLPrefix string
+}
+
+// Wrap is a WrapFunc which can be used as the WrapFunc in [Config].
+func (p ProtoAnyWrapper) Wrap(typ string, v jsontext.Value) WrappedValue {
+	prefix := defaultTypeURLPrefix
+	if p.TypeURLPrefix != "" {
+		prefix = p.TypeURLPrefix
+		if !strings.HasSuffix(prefix, "/") {
+			prefix += "/"
+		}
+	}
+	return protoAnyWrappedValue{
+		prefix: prefix,
+		typ:    typ,
+		value:  v,
+	}
+}
+
+type protoAnyWrappedValue struct {
+	prefix string
+	typ    string
+	value  jsontext.Value
+	tok    jsontext.Value
+}
+
+func (w protoAnyWrappedValue) Type() string                { return w.typ }
+func (w protoAnyWrappedValue) Value() jsontext.Value       { return w.value }
+func (w protoAnyWrappedValue) token() jsontext.Value       { return w.tok }
+func (w protoAnyWrappedValue) discriminatorMember() string { return "@type" }
+
+func (w protoAnyWrappedValue) MarshalJSONV2(enc *jsontext.Encoder, opts json.Options) error {
+	u, err := json.Marshal(w.prefix + w.typ)
+	if err != nil {
+		return fmt.Errorf("failed to encode type URL: %w", err)
+	}
+	root := jsonObject{{name: "@type", value: jsontext.Value(u)}}
+
+	switch {
+	case protobufWellKnownTypes[w.typ]:
+		root = append(root, jsonMember{name: "value", value: valueOrNull(w.value)})
+	case len(w.value) == 0:
+		// Don't write a value
+	case w.value.Kind() == '{':
+		var value jsonObject
+		if err := value.parse(w.value); err != nil {
+			return fmt.Errorf("failed to parse value: %w", err)
+		}
+		if value.index("@type") >= 0 {
+			return fmt.Errorf("value already contains @type")
+		}
+		root = append(root, value...)
+	default:
+		return fmt.Errorf("message %s must encode to a JSON object (got %v)", w.typ, w.value.Kind())
+	}
+	return root.encode(enc)
+}
+
+func (w *protoAnyWrappedValue) UnmarshalJSONV2(dec *jsontext.Decoder, opts json.Options) error {
+	if k := dec.PeekKind(); k != '{' {
+		return fmt.Errorf("expected object start, but encountered %v", k)
+	}
+
+	var root jsonObject
+	if err := root.decode(dec); err != nil {
+		return err
+	}
+
+	tv, ok := root.take([]string{"@type"})
+	if !ok {
+		return fmt.Errorf(`missing "@type"`)
+	}
+	jv, ok := tv.(jsontext.Value)
+	if !ok || jv.Kind() != '"' {
+		return fmt.Errorf(`value for "@type" must be a string`)
+	}
+	var typeURL string
+	if err := json.Unmarshal(jv, &typeURL); err != nil {
+		return fmt.Errorf("failed to decode type URL: %w", err)
+	}
+
+	// Only the last path segment of the type URL names
+	// the message
+	name := typeURL[strings.LastIndex(typeURL, "/")+1:]
+	if name == "" {
+		return fmt.Errorf("invalid type URL %q", typeURL)
+	}
+	w.typ = name
+	w.tok = jv
+
+	if protobufWellKnownTypes[name] {
+		v, ok := root.take([]string{"value"})
+		if len(root) > 0 {
+			return fmt.Errorf(`unexpected data alongside the "value" of well-known type %s: %s`, name, encodeJSONTree(root))
+		}
+		if ok {
+			w.value = encodeJSONTree(v)
+		}
+		return nil
+	}
+
+	if len(root) > 0 {
+		w.value = encodeJSONTree(root)
+	}
+	return nil
+}
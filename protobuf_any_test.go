@@ -0,0 +1,135 @@
+package oneof_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/dhoelle/oneof"
+	"github.com/go-json-experiment/json"
+)
+
+type protoMessage interface{ isProtoMessage() }
+
+type protoBucket struct {
+	Bucket string `json:"bucket"`
+	Region string `json:"region,omitempty"`
+}
+
+type protoDuration string
+
+type protoEmpty struct{}
+
+func (protoBucket) isProtoMessage()   {}
+func (protoDuration) isProtoMessage() {}
+func (protoEmpty) isProtoMessage()    {}
+
+type protoEnvelope struct {
+	Details []protoMessage `json:"details"`
+}
+
+func Test_ProtoAnyWrapper(t *testing.T) {
+	r := oneof.NewRegistry[protoMessage]().
+		MustRegister("acme.storage.v1.Bucket", protoBucket{}).
+		MustRegister("google.protobuf.Duration", protoDuration("")).
+		MustRegister("google.protobuf.Empty", protoEmpty{})
+	opts := r.JSONOptions(&oneof.Config{WrapFunc: oneof.ProtoAnyWrapper{}.Wrap})
+
+	in := protoEnvelope{Details: []protoMessage{
+		protoBucket{Bucket: "x"},
+		protoDuration("1.5s"),
+		protoEmpty{},
+	}}
+	want := `{"details":[` +
+		`{"@type":"type.googleapis.com/acme.storage.v1.Bucket","bucket":"x"},` +
+		`{"@type":"type.googleapis.com/google.protobuf.Duration","value":"1.5s"},` +
+		`{"@type":"type.googleapis.com/google.protobuf.Empty","value":{}}]}`
+
+	t.Run("marshal", func(t *testing.T) {
+		b, err := json.Marshal(in, opts)
+		if err != nil {
+			t.Fatalf("error marshaling: %v", err)
+		}
+		if string(b) != want {
+			t.Errorf("got:\n%s\nwant:\n%s", b, want)
+		}
+	})
+
+	t.Run("unmarshal", func(t *testing.T) {
+		var out protoEnvelope
+		if err := json.Unmarshal([]byte(want), &out, opts); err != nil {
+			t.Fatalf("error unmarshaling: %v", err)
+		}
+		if !reflect.DeepEqual(out, in) {
+			t.Errorf("got %#v, want %#v", out, in)
+		}
+	})
+
+	t.Run("custom prefix", func(t *testing.T) {
+		opts := r.JSONOptions(&oneof.Config{WrapFunc: oneof.ProtoAnyWrapper{TypeURLPrefix: "types.acme.dev"}.Wrap})
+		b, err := json.Marshal(protoEnvelope{Details: []protoMessage{protoBucket{Bucket: "x"}}}, opts)
+		if err != nil {
+			t.Fatalf("error marshaling: %v", err)
+		}
+		want := `{"details":[{"@type":"types.acme.dev/acme.storage.v1.Bucket","bucket":"x"}]}`
+		if string(b) != want {
+			t.Errorf("got:\n%s\nwant:\n%s", b, want)
+		}
+	})
+
+	tests := []struct {
+		name string
+		in   string
+		want protoMessage
+	}{
+		{
+			name: "any prefix",
+			in:   `{"region":"eu","@type":"example.com/a/b/acme.storage.v1.Bucket","bucket":"x"}`,
+			want: protoBucket{Bucket: "x", Region: "eu"},
+		},
+		{
+			name: "no prefix",
+			in:   `{"@type":"acme.storage.v1.Bucket","bucket":"x"}`,
+			want: protoBucket{Bucket: "x"},
+		},
+		{
+			name: "well-known type without value",
+			in:   `{"@type":"type.googleapis.com/google.protobuf.Empty"}`,
+			want: protoEmpty{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out protoEnvelope
+			if err := json.Unmarshal([]byte(`{"details":[`+tt.in+`]}`), &out, opts); err != nil {
+				t.Fatalf("error unmarshaling: %v", err)
+			}
+			if len(out.Details) != 1 || !reflect.DeepEqual(out.Details[0], tt.want) {
+				t.Errorf("got %#v, want %#v", out.Details, tt.want)
+			}
+		})
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		for _, in := range []string{
+			`{"bucket":"x"}`,
+			`{"@type":"type.googleapis.com/"}`,
+			`{"@type":"type.googleapis.com/google.protobuf.Duration","value":"1s","extra":1}`,
+		} {
+			var out protoEnvelope
+			if err := json.Unmarshal([]byte(`{"details":[`+in+`]}`), &out, opts); err == nil {
+				t.Errorf("%s: expected an error", in)
+			}
+		}
+
+		var out protoEnvelope
+		err := json.Unmarshal([]byte(`{"details":[{"@type":"type.googleapis.com/acme.Unknown"}]}`), &out, opts)
+		var unknown oneof.ErrUnknownDiscriminatorValue
+		if !errors.As(err, &unknown) {
+			t.Fatalf("got error %v, want ErrUnknownDiscriminatorValue", err)
+		}
+		if got, want := string(unknown.Token()), `"type.googleapis.com/acme.Unknown"`; got != want {
+			t.Errorf("got token %s, want %s", got, want)
+		}
+	})
+}
@@ -0,0 +1,6 @@
+//go:build race
+
+package oneof
+
+// sync.Pool deliberately drops items when the race detector is enabled
+const raceEnabled = true
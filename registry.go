@@ -0,0 +1,306 @@
+package oneof
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/go-json-experiment/json"
+)
+
+// Registry is an ordered set of options for an interface type T, keyed by
+// their discriminator values.
+//
+// Options are registered explicitly, in order, with [Registry.Register].
+// Registration rejects duplicate keys and duplicate Go types, so that every
+// Go type maps to exactly one discriminator value.
+//
+// A Registry is frozen by its first use: calling [Registry.MarshalFunc],
+// [Registry.UnmarshalFunc], [Registry.JSONOptions] or [Registry.Freeze]
+// prevents further registration. A frozen Registry is safe for concurrent use.
+//
+//	r := oneof.NewRegistry[fmt.Stringer]()
+//	if err := r.Register("crypto.Hash", crypto.Hash(0)); err != nil {
+//	  return err
+//	}
+//	if err := r.Register("url.URL", &url.URL{}); err != nil {
+//	  return err
+//	}
+//	b, err := json.Marshal(v, r.JSONOptions(nil))
+type Registry[T any] struct {
+	mu      sync.Mutex
+	frozen  bool
+	entries []registryEntry
+	byKey   map[string]int // index into entries
+
+	shorthands     []shorthandEntry[T]
+	shorthandByKey map[string]int // index into shorthands
+}
+
+// registryEntry is a single option in a [Registry].
+type registryEntry struct {
+	key string
+	typ reflect.Type // the concrete Go type of the option
+
+	// factory, if non-nil, creates new values of typ to decode
+	// into. See [Registry.RegisterFunc].
+	factory func() any
+}
+
+// NewRegistry creates an empty [Registry] for options of type T.
+func NewRegistry[T any]() *Registry[T] {
+	return &Registry[T]{
+		byKey: map[string]int{},
+	}
+}
+
+// Register adds the option v to r under the discriminator value key.
+//
+// Register returns an error if r is frozen, if v is nil, or if key or the Go
+// type of v has already been registered.
+func (r *Registry[T]) Register(key string, v T) error {
+	typ := reflect.TypeOf(v)
+	if typ == nil {
+		return fmt.Errorf("cannot register nil option for key %q", key)
+	}
+	return r.add(key, typ, nil, false)
+}
+
+// MustRegister is like [Registry.Register], but panics if v cannot be
+// registered. It returns r, so that calls may be chained.
+func (r *Registry[T]) MustRegister(key string, v T) *Registry[T] {
+	if err := r.Register(key, v); err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// RegisterFunc adds an option to r under the discriminator value key, which
+// [Registry.UnmarshalFunc] creates by calling fn rather than by allocating a
+// zero value. Use RegisterFunc for types that must be initialized by a
+// constructor (for example, types with an internal cache or lock-guarded
+// map): the remaining JSON is decoded into the value returned by fn.
+//
+// fn is called once by RegisterFunc to determine the option's Go type, and
+// once for every decoded value. It must return a non-nil value of the same Go
+// type every time; pointer types must return a new pointer every time.
+//
+// RegisterFunc returns an error for any reason that [Registry.Register] would.
+func (r *Registry[T]) RegisterFunc(key string, fn func() T) error {
+	if fn == nil {
+		return fmt.Errorf("cannot register nil func for key %q", key)
+	}
+	typ := reflect.TypeOf(fn())
+	if typ == nil {
+		return fmt.Errorf("cannot register func for key %q: func returned a nil option", key)
+	}
+	return r.add(key, typ, func() any { return fn() }, false)
+}
+
+// MustRegisterFunc is like [Registry.RegisterFunc], but panics if fn cannot
+// be registered. It returns r, so that calls may be chained.
+func (r *Registry[T]) MustRegisterFunc(key string, fn func() T) *Registry[T] {
+	if err := r.RegisterFunc(key, fn); err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Register adds the Go type C to r as an option under the discriminator value
+// key, without requiring a prototype value:
+//
+//	oneof.Register[fmt.Stringer, *url.URL](r, "url.URL")
+//
+// Register returns an error if values of type C cannot be assigned to T (for
+// example, if only *C implements the interface T), if C is an interface type,
+// or for any reason that [Registry.Register] would.
+//
+// Go's type parameters cannot express "C implements T" for a type parameter T,
+// so this is checked when Register is called. Use [MustRegister] while
+// initializing a program to catch mistakes at startup.
+func Register[T any, C any](r *Registry[T], key string) error {
+	typeT := reflect.TypeOf((*T)(nil)).Elem()
+	typeC := reflect.TypeOf((*C)(nil)).Elem()
+
+	if typeC.Kind() == reflect.Interface {
+		return fmt.Errorf("cannot register interface type %v for key %q: options must be concrete types", typeC, key)
+	}
+	if !typeC.AssignableTo(typeT) {
+		if reflect.PointerTo(typeC).AssignableTo(typeT) {
+			return fmt.Errorf("cannot register %v for key %q: %v does not implement %v (but %v does)", typeC, key, typeC, typeT, reflect.PointerTo(typeC))
+		}
+		return fmt.Errorf("cannot register %v for key %q: %v is not assignable to %v", typeC, key, typeC, typeT)
+	}
+
+	return r.add(key, typeC, nil, false)
+}
+
+// MustRegister is like [Register], but panics if C cannot be registered.
+func MustRegister[T any, C any](r *Registry[T], key string) {
+	if err := Register[T, C](r, key); err != nil {
+		panic(err)
+	}
+}
+
+func (r *Registry[T]) add(key string, typ reflect.Type, factory func() any, allowDuplicateTypes bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.frozen {
+		return ErrRegistryFrozen{key: key}
+	}
+	if _, ok := r.byKey[key]; ok {
+		return ErrDuplicateKey{key: key}
+	}
+	if !allowDuplicateTypes {
+		for _, e := range r.entries {
+			if e.typ == typ {
+				return ErrDuplicateGoType{typ: typ.String(), key: key, existingKey: e.key}
+			}
+		}
+	}
+
+	r.byKey[key] = len(r.entries)
+	r.entries = append(r.entries, registryEntry{
+		key:     key,
+		typ:     typ,
+		factory: factory,
+	})
+	return nil
+}
+
+// Freeze prevents further registration in r.
+func (r *Registry[T]) Freeze() {
+	r.mu.Lock()
+	r.frozen = true
+	r.mu.Unlock()
+}
+
+// Keys returns the discriminator values in r, in registration order.
+func (r *Registry[T]) Keys() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := make([]string, len(r.entries))
+	for i, e := range r.entries {
+		keys[i] = e.key
+	}
+	return keys
+}
+
+// JSONOptions freezes r and returns [json.Options] which include both
+// [Registry.MarshalFunc] and [Registry.UnmarshalFunc].
+func (r *Registry[T]) JSONOptions(cfg *Config) json.Options {
+	return json.JoinOptions(
+		json.WithMarshalers(
+			r.MarshalFunc(cfg),
+		),
+		json.WithUnmarshalers(
+			r.UnmarshalFunc(cfg),
+		),
+	)
+}
+
+// lookupKey returns the option registered under key.
+func (r *Registry[T]) lookupKey(key string) (registryEntry, bool) {
+	i, ok := r.byKey[key]
+	if !ok {
+		return registryEntry{}, false
+	}
+	return r.entries[i], true
+}
+
+// newValue creates a new value of the option type to decode into.
+func (e registryEntry) newValue() (newValue, error) {
+	if e.factory == nil {
+		return newValueOf(e.typ)
+	}
+
+	v := reflect.ValueOf(e.factory())
+	if v.Type() != e.typ {
+		return newValue{}, fmt.Errorf("func for key %s returned %v, want %v", e.key, v.Type(), e.typ)
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return newValue{}, fmt.Errorf("func for key %s returned a nil %v", e.key, e.typ)
+		}
+		return newValue{target: v, result: v}, nil
+	}
+	p := reflect.New(e.typ)
+	p.Elem().Set(v)
+	return newValue{target: p, result: p.Elem()}, nil
+}
+
+// typeIndex maps Go types to the discriminator values of the options in a
+// [Registry], so that finding the discriminator for a value takes constant
+// time regardless of the number of options.
+type typeIndex struct {
+	keys   map[reflect.Type]string
+	strict bool
+}
+
+// typeIndex builds a [typeIndex] of the options in r. If several options
+// match the same type, the first registered option wins.
+//
+// Unless strict is true, pointer and non-pointer forms of the same type match
+// each other: the index is keyed by the non-pointer form.
+func (r *Registry[T]) typeIndex(strict bool) typeIndex {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	idx := typeIndex{
+		keys:   make(map[reflect.Type]string, len(r.entries)),
+		strict: strict,
+	}
+	for _, e := range r.entries {
+		typ := e.typ
+		if typ == nil {
+			continue
+		}
+		if !strict && typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		if _, ok := idx.keys[typ]; !ok {
+			idx.keys[typ] = e.key
+		}
+	}
+	return idx
+}
+
+// keyFor returns the key of the option whose type matches the type of v,
+// according to [reflect.TypeOf].
+func (idx typeIndex) keyFor(v any) (string, bool) {
+	typ := reflect.TypeOf(v)
+	if typ == nil {
+		return "", false
+	}
+	if !idx.strict && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	key, ok := idx.keys[typ]
+	return key, ok
+}
+
+// registryFromMap adapts a map of options, as accepted by [MarshalFunc] and
+// [UnmarshalFunc], to a frozen [Registry].
+//
+// Options are registered in lexicographical order of their keys, so if
+// several keys map to the same Go type, [MarshalFunc] consistently uses the
+// first of them. For compatibility, the map may contain such duplicate Go
+// types (and nil options, which fail to unmarshal).
+func registryFromMap[T any](opts map[string]T) *Registry[T] {
+	keys := make([]string, 0, len(opts))
+	for k := range opts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	r := NewRegistry[T]()
+	for _, k := range keys {
+		v := opts[k]
+		_ = r.add(k, reflect.TypeOf(v), nil, true) // keys are unique
+	}
+	r.Freeze()
+	return r
+}
@@ -0,0 +1,104 @@
+package oneof_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/dhoelle/oneof"
+	"github.com/go-json-experiment/json"
+)
+
+// Benchmark_MarshalOptionCount marshals the same number of values with
+// increasingly large sets of options. The cost per value should not grow with
+// the number of options.
+func Benchmark_MarshalOptionCount(b *testing.B) {
+	for _, n := range []int{1, 10, 100, 1000} {
+		b.Run(fmt.Sprintf("options=%d", n), func(b *testing.B) {
+			// Create n distinct Go types, like struct{ F0 bool }
+			r := oneof.NewRegistry[any]()
+			var protos []any
+			for i := 0; i < n; i++ {
+				typ := reflect.StructOf([]reflect.StructField{{
+					Name: fmt.Sprintf("F%d", i),
+					Type: reflect.TypeOf(false),
+				}})
+				proto := reflect.New(typ).Elem().Interface()
+				r.MustRegister(fmt.Sprintf("option%d", i), proto)
+				protos = append(protos, proto)
+			}
+
+			// Marshal the most recently registered options, which
+			// would be found last by a linear search
+			var in []any
+			for i := 0; i < 16; i++ {
+				in = append(in, protos[len(protos)-1-i%len(protos)])
+			}
+			opts := r.JSONOptions(&oneof.Config{StaticTypeOnly: true})
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := json.Marshal(in, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// Benchmark_UnmarshalAnyDepth decodes increasingly deep JSON objects without
+// discriminators into values of type any. Objects which are not wrapped
+// should not be decoded again for every level above them.
+func Benchmark_UnmarshalAnyDepth(b *testing.B) {
+	r := oneof.NewRegistry[any]().MustRegister("literal", LiteralStringer(""))
+	opts := r.JSONOptions(&oneof.Config{WrapFunc: oneof.CustomValueWrapper{DiscriminatorKey: "kind"}.Wrap})
+
+	for _, depth := range []int{1, 4, 16, 64} {
+		b.Run(fmt.Sprintf("depth=%d", depth), func(b *testing.B) {
+			in := `{"leaf":true}`
+			for i := 0; i < depth; i++ {
+				in = `{"a":1,"b":"x","c":` + in + `}`
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				var v any
+				if err := json.Unmarshal([]byte(in), &v, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// Benchmark_MarshalParallel marshals and unmarshals wrapped values from many
+// goroutines at once, with the same options.
+func Benchmark_MarshalParallel(b *testing.B) {
+	r := oneof.NewRegistry[fmt.Stringer]().
+		MustRegister("literal", LiteralStringer("")).
+		MustRegister("join", JoinStringer{}).
+		MustRegister("exclamation", ExclamationPointsStringer(0))
+	opts := r.JSONOptions(nil)
+
+	var in []fmt.Stringer
+	for i := 0; i < 16; i++ {
+		in = append(in, JoinStringer{A: LiteralStringer("a"), B: ExclamationPointsStringer(i)})
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			data, err := json.Marshal(in, opts)
+			if err != nil {
+				b.Fatal(err)
+			}
+			var out []fmt.Stringer
+			if err := json.Unmarshal(data, &out, opts); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,192 @@
+package oneof_test
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/dhoelle/oneof"
+	"github.com/go-json-experiment/json"
+)
+
+func Test_RegistryRegister(t *testing.T) {
+	r := oneof.NewRegistry[fmt.Stringer]()
+	if err := r.Register("url.URL", &url.URL{}); err != nil {
+		t.Fatalf("error registering: %v", err)
+	}
+	if err := r.Register("crypto.Hash", crypto.Hash(0)); err != nil {
+		t.Fatalf("error registering: %v", err)
+	}
+
+	if err := r.Register("url.URL", LiteralStringer("")); !errors.As(err, &oneof.ErrDuplicateKey{}) {
+		t.Errorf("got error %v, want ErrDuplicateKey", err)
+	}
+	if err := r.Register("hash", crypto.SHA256); !errors.As(err, &oneof.ErrDuplicateGoType{}) {
+		t.Errorf("got error %v, want ErrDuplicateGoType", err)
+	}
+	if err := r.Register("nil", nil); err == nil {
+		t.Errorf("registering a nil option: got nil error")
+	}
+
+	if got, want := r.Keys(), []string{"url.URL", "crypto.Hash"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got keys %v, want %v", got, want)
+	}
+
+	_ = r.MarshalFunc(nil)
+	if err := r.Register("literal", LiteralStringer("")); !errors.As(err, &oneof.ErrRegistryFrozen{}) {
+		t.Errorf("got error %v, want ErrRegistryFrozen", err)
+	}
+}
+
+func Test_RegistryRoundTrip(t *testing.T) {
+	r := oneof.NewRegistry[fmt.Stringer]().
+		MustRegister("literal", LiteralStringer("")).
+		MustRegister("join", JoinStringer{}).
+		MustRegister("exclamation", ExclamationPointsStringer(0))
+	opts := r.JSONOptions(nil)
+
+	var in fmt.Stringer = JoinStringer{
+		A:         LiteralStringer("Hello"),
+		Separator: " ",
+		B:         ExclamationPointsStringer(3),
+	}
+	b, err := json.Marshal(in, opts)
+	if err != nil {
+		t.Fatalf("error marshaling: %v", err)
+	}
+	var out fmt.Stringer
+	if err := json.Unmarshal(b, &out, opts); err != nil {
+		t.Fatalf("error unmarshaling: %v", err)
+	}
+	if got, want := out.String(), "Hello !!!"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func Test_MapOptionsDuplicateTypesAreDeterministic(t *testing.T) {
+	opts := map[string]fmt.Stringer{
+		"sha": crypto.Hash(0),
+		"md5": crypto.Hash(0),
+		"b":   crypto.Hash(0),
+		"a":   crypto.Hash(0),
+	}
+	for i := 0; i < 20; i++ {
+		b, err := json.Marshal(crypto.SHA256, json.WithMarshalers(oneof.MarshalFunc(opts, nil)))
+		if err != nil {
+			t.Fatalf("error marshaling: %v", err)
+		}
+		if want := `{"_type":"a","_value":5}`; string(b) != want {
+			t.Fatalf("got %s, want %s", b, want)
+		}
+	}
+}
+
+func Test_RegisterType(t *testing.T) {
+	r := oneof.NewRegistry[fmt.Stringer]()
+	if err := oneof.Register[fmt.Stringer, *url.URL](r, "url.URL"); err != nil {
+		t.Fatalf("error registering: %v", err)
+	}
+	if err := oneof.Register[fmt.Stringer, crypto.Hash](r, "crypto.Hash"); err != nil {
+		t.Fatalf("error registering: %v", err)
+	}
+
+	// Only *url.URL implements fmt.Stringer
+	if err := oneof.Register[fmt.Stringer, url.URL](r, "url.URL (value)"); err == nil {
+		t.Errorf("registering url.URL: got nil error")
+	}
+	// Interfaces cannot be instantiated
+	if err := oneof.Register[fmt.Stringer, fmt.Stringer](r, "stringer"); err == nil {
+		t.Errorf("registering fmt.Stringer: got nil error")
+	}
+	if err := oneof.Register[fmt.Stringer, crypto.Hash](r, "hash"); !errors.As(err, &oneof.ErrDuplicateGoType{}) {
+		t.Errorf("got error %v, want ErrDuplicateGoType", err)
+	}
+
+	opts := r.JSONOptions(nil)
+	in := []fmt.Stringer{&url.URL{Host: "a.example.com"}, crypto.SHA256}
+	b, err := json.Marshal(in, opts)
+	if err != nil {
+		t.Fatalf("error marshaling: %v", err)
+	}
+	var out []fmt.Stringer
+	if err := json.Unmarshal(b, &out, opts); err != nil {
+		t.Fatalf("error unmarshaling: %v", err)
+	}
+	if u, ok := out[0].(*url.URL); !ok || u.Host != "a.example.com" {
+		t.Errorf("got %#v, want &url.URL{Host: \"a.example.com\"}", out[0])
+	}
+	if out[1] != crypto.SHA256 {
+		t.Errorf("got %#v, want crypto.SHA256", out[1])
+	}
+}
+
+func Test_MustRegisterPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("MustRegister did not panic")
+		}
+	}()
+	oneof.MustRegister[fmt.Stringer, url.URL](oneof.NewRegistry[fmt.Stringer](), "url.URL")
+}
+
+// cachingStringer must be created by newCachingStringer
+type cachingStringer struct {
+	Name  string `json:"name"`
+	cache map[string]string
+}
+
+func newCachingStringer() *cachingStringer {
+	return &cachingStringer{cache: map[string]string{}}
+}
+
+func (s *cachingStringer) String() string {
+	if v, ok := s.cache[s.Name]; ok {
+		return v
+	}
+	v := "cached " + s.Name
+	s.cache[s.Name] = v // panics if cache was not initialized
+	return v
+}
+
+func Test_RegisterFunc(t *testing.T) {
+	r := oneof.NewRegistry[fmt.Stringer]().
+		MustRegisterFunc("caching", func() fmt.Stringer { return newCachingStringer() }).
+		MustRegister("literal", LiteralStringer(""))
+	opts := r.JSONOptions(nil)
+
+	in := []byte(`[
+		{"_type":"caching","_value":{"name":"a"}},
+		{"_type":"caching","_value":{"name":"b"}},
+		{"_type":"caching"},
+		{"_type":"literal","_value":"c"}
+	]`)
+	var out []fmt.Stringer
+	if err := json.Unmarshal(in, &out, opts); err != nil {
+		t.Fatalf("error unmarshaling: %v", err)
+	}
+	if out[0] == out[1] {
+		t.Fatalf("factory values alias each other")
+	}
+	var got []string
+	for _, s := range out {
+		got = append(got, s.String())
+	}
+	if want := []string{"cached a", "cached b", "cached ", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	b, err := json.Marshal(out[:1], opts)
+	if err != nil {
+		t.Fatalf("error marshaling: %v", err)
+	}
+	if want := `[{"_type":"caching","_value":{"name":"a"}}]`; string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+
+	if err := oneof.NewRegistry[fmt.Stringer]().RegisterFunc("nil", func() fmt.Stringer { return nil }); err == nil {
+		t.Errorf("registering a func returning nil: got nil error")
+	}
+}
@@ -0,0 +1,305 @@
+package oneof
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// The placeholder for the version in a [SchemaWrapper] pattern
+const schemaVersionPlaceholder = "{version}"
+
+// The default JSON object key for schema URLs
+const defaultSchemaKey = "$schema"
+
+// SchemaWrapper identifies options by a schema URL, such as the "$schema"
+// member of a configuration file:
+//
+//	{
+//	  "$schema": "https://acme.dev/schemas/storage/s3/v2.json",
+//	  "bucket": "x"
+//	}
+//
+// Each discriminator value is registered with one or more URL patterns, which
+// may contain a {version} placeholder:
+//
+//	sw := oneof.NewSchemaWrapper().
+//	  MustRegister("s3", "https://acme.dev/schemas/storage/s3/v{version}.json", "2")
+//	cfg := &oneof.Config{WrapFunc: sw.Wrap}
+//
+// When unmarshaling, the schema URL is matched against the patterns in
+// registration order, and the text matched by {version} is handed to decoded
+// values which implement [SchemaVersionKeeper], and to OnDecode. When
+// marshaling, the canonical URL of the option is written.
+//
+// Object values are inlined next to the schema URL; other values are nested
+// under NestedValueKey.
+//
+// Like a [Registry], a SchemaWrapper is frozen by its first use: calling
+// [SchemaWrapper.Wrap], [SchemaWrapper.URL], [SchemaWrapper.Match] or
+// [SchemaWrapper.Freeze] prevents further registration. A frozen
+// SchemaWrapper is safe for concurrent use.
+type SchemaWrapper struct {
+	// Key is the JSON object key of the schema URL. Defaults to "$schema".
+	Key string
+
+	// NestedValueKey is the JSON object key of values which are not JSON
+	// objects. Defaults to "_value".
+	NestedValueKey string
+
+	// OnDecode, if non-nil, is called with the discriminator value and
+	// schema version of every decoded value, and a pointer to the value.
+	// An error from OnDecode fails unmarshaling.
+	OnDecode func(key, version string, v any) error
+
+	mu        sync.Mutex
+	frozen    bool
+	freeze    sync.Once
+	patterns  []schemaPattern
+	canonical map[string]string // discriminator value -> canonical URL
+}
+
+// SchemaVersionKeeper is implemented by option types which keep the schema
+// version that a [SchemaWrapper] found when decoding them.
+//
+// SetOneofSchemaVersion is called on a pointer to the decoded value.
+type SchemaVersionKeeper interface {
+	SetOneofSchemaVersion(version string)
+}
+
+// schemaPattern is a URL pattern registered with a [SchemaWrapper].
+type schemaPattern struct {
+	key string
+
+	// A pattern with a version matches URLs which start with
+	// prefix, end with suffix, and have a non-empty version
+	// between them. Otherwise, it matches only prefix.
+	prefix, suffix string
+	versioned      bool
+}
+
+// NewSchemaWrapper creates a [SchemaWrapper] with no patterns.
+func NewSchemaWrapper() *SchemaWrapper {
+	return &SchemaWrapper{canonical: map[string]string{}}
+}
+
+// Register adds the URL pattern for the discriminator value key. pattern may
+// contain one {version} placeholder, which matches any non-empty text that
+// does not contain a "/".
+//
+// The first pattern registered for key, with {version} replaced by version,
+// is the canonical URL written when marshaling. Later patterns for key (for
+// example, for a legacy host) only match when unmarshaling, and their
+// version is ignored.
+//
+// Register returns an error if s is frozen, if pattern contains several
+// placeholders, or if the first pattern for key has a placeholder but version
+// is empty.
+func (s *SchemaWrapper) Register(key, pattern, version string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.frozen {
+		return ErrRegistryFrozen{key: key}
+	}
+
+	p := schemaPattern{key: key, prefix: pattern}
+	switch strings.Count(pattern, schemaVersionPlaceholder) {
+	case 0:
+	case 1:
+		p.prefix, p.suffix, _ = strings.Cut(pattern, schemaVersionPlaceholder)
+		p.versioned = true
+	default:
+		return fmt.Errorf("schema pattern %q for key %s contains several %s placeholders", pattern, key, schemaVersionPlaceholder)
+	}
+
+	if _, ok := s.canonical[key]; !ok {
+		if p.versioned && version == "" {
+			return fmt.Errorf("schema pattern %q for key %s requires a version", pattern, key)
+		}
+		if s.canonical == nil {
+			s.canonical = map[string]string{}
+		}
+		s.canonical[key] = strings.Replace(pattern, schemaVersionPlaceholder, version, 1)
+	}
+	s.patterns = append(s.patterns, p)
+	return nil
+}
+
+// MustRegister is like [SchemaWrapper.Register], but panics if the pattern
+// cannot be registered. It returns s, so that calls may be chained.
+func (s *SchemaWrapper) MustRegister(key, pattern, version string) *SchemaWrapper {
+	if err := s.Register(key, pattern, version); err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Freeze prevents further registration in s.
+func (s *SchemaWrapper) Freeze() {
+	// Freeze is called for every wrapped value, so only
+	// take the lock the first time
+	s.freeze.Do(func() {
+		s.mu.Lock()
+		s.frozen = true
+		s.mu.Unlock()
+	})
+}
+
+// URL freezes s and returns the canonical schema URL of the discriminator
+// value key.
+func (s *SchemaWrapper) URL(key string) (string, bool) {
+	s.Freeze()
+
+	u, ok := s.canonical[key]
+	return u, ok
+}
+
+// Match freezes s and returns the discriminator value and version of the
+// first pattern which matches url.
+func (s *SchemaWrapper) Match(url string) (key, version string, ok bool) {
+	s.Freeze()
+
+	for _, p := range s.patterns {
+		if !p.versioned {
+			if url == p.prefix {
+				return p.key, "", true
+			}
+			continue
+		}
+		if len(url) <= len(p.prefix)+len(p.suffix) || !strings.HasPrefix(url, p.prefix) || !strings.HasSuffix(url, p.suffix) {
+			continue
+		}
+		version := url[len(p.prefix) : len(url)-len(p.suffix)]
+		if strings.Contains(version, "/") {
+			continue
+		}
+		return p.key, version, true
+	}
+	return "", "", false
+}
+
+// Wrap freezes s, and wraps the discriminator value typ and value v. It can be
+// used as [Config.WrapFunc].
+func (s *SchemaWrapper) Wrap(typ string, v jsontext.Value) WrappedValue {
+	s.Freeze()
+
+	key := defaultSchemaKey
+	if s.Key != "" {
+		key = s.Key
+	}
+	nestedValueKey := defaultNestedValueKey
+	if s.NestedValueKey != "" {
+		nestedValueKey = s.NestedValueKey
+	}
+	return schemaWrappedValue{
+		s:              s,
+		key:            key,
+		nestedValueKey: nestedValueKey,
+		typ:            typ,
+		value:          v,
+	}
+}
+
+// decodeHookWrappedValue is implemented by [WrappedValue]s which act on the
+// value decoded from them. afterDecode is called with a pointer to the
+// decoded value.
+type decodeHookWrappedValue interface {
+	WrappedValue
+	afterDecode(v any) error
+}
+
+// schemaWrappedValue is the [WrappedValue] created by a [SchemaWrapper].
+type schemaWrappedValue struct {
+	s              *SchemaWrapper
+	key            string
+	nestedValueKey string
+
+	typ     string
+	value   jsontext.Value
+	version string
+}
+
+func (w schemaWrappedValue) Type() string                { return w.typ }
+func (w schemaWrappedValue) Value() jsontext.Value       { return w.value }
+func (w schemaWrappedValue) discriminatorMember() string { return w.key }
+
+func (w schemaWrappedValue) afterDecode(v any) error {
+	if k, ok := v.(SchemaVersionKeeper); ok {
+		k.SetOneofSchemaVersion(w.version)
+	}
+	if w.s.OnDecode != nil {
+		return w.s.OnDecode(w.typ, w.version, v)
+	}
+	return nil
+}
+
+func (w schemaWrappedValue) MarshalJSONV2(enc *jsontext.Encoder, opts json.Options) error {
+	url, ok := w.s.URL(w.typ)
+	if !ok {
+		return fmt.Errorf("no schema URL for key %s", w.typ)
+	}
+
+	u, err := json.Marshal(url)
+	if err != nil {
+		return fmt.Errorf("failed to encode schema URL %s: %w", url, err)
+	}
+
+	root := jsonObject{{name: w.key, value: jsontext.Value(u)}}
+	switch {
+	case len(w.value) == 0:
+		// Don't write a value
+	case w.value.Kind() == '{':
+		var value jsonObject
+		if err := value.parse(w.value); err != nil {
+			return fmt.Errorf("failed to parse value: %w", err)
+		}
+		if value.index(w.key) >= 0 {
+			return fmt.Errorf("value already contains schema key %s", w.key)
+		}
+		root = append(root, value...)
+	default:
+		root = append(root, jsonMember{name: w.nestedValueKey, value: w.value})
+	}
+	return root.encode(enc)
+}
+
+func (w *schemaWrappedValue) UnmarshalJSONV2(dec *jsontext.Decoder, opts json.Options) error {
+	if k := dec.PeekKind(); k != '{' {
+		return fmt.Errorf("expected object start, but encountered %v", k)
+	}
+
+	var root jsonObject
+	if err := root.decode(dec); err != nil {
+		return err
+	}
+
+	sv, ok := root.take([]string{w.key})
+	if !ok {
+		return fmt.Errorf(`missing schema "%s"`, w.key)
+	}
+	jv, ok := sv.(jsontext.Value)
+	if !ok || jv.Kind() != '"' {
+		return fmt.Errorf(`value for schema key "%s" must be a string`, w.key)
+	}
+	var url string
+	if err := json.Unmarshal(jv, &url); err != nil {
+		return fmt.Errorf("failed to decode schema: %w", err)
+	}
+	typ, version, ok := w.s.Match(url)
+	if !ok {
+		return ErrUnknownDiscriminatorValue{v: url, token: jv}
+	}
+	w.typ, w.version = typ, version
+
+	switch {
+	case len(root) == 1 && root[0].name == w.nestedValueKey:
+		w.value = encodeJSONTree(root[0].value)
+	case len(root) > 0:
+		w.value = encodeJSONTree(root)
+	}
+	return nil
+}
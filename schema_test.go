@@ -0,0 +1,157 @@
+package oneof_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dhoelle/oneof"
+	"github.com/go-json-experiment/json"
+)
+
+type schemaStorage interface{ schemaStorage() }
+
+type s3Storage struct {
+	Bucket  string `json:"bucket"`
+	Version string `json:"-"`
+}
+
+func (s3Storage) schemaStorage() {}
+
+func (s *s3Storage) SetOneofSchemaVersion(version string) { s.Version = version }
+
+type memStorage int
+
+func (memStorage) schemaStorage() {}
+
+type schemaConfig struct {
+	Storage schemaStorage `json:"storage"`
+}
+
+func Test_SchemaWrapper(t *testing.T) {
+	r := oneof.NewRegistry[schemaStorage]().
+		MustRegister("s3", s3Storage{}).
+		MustRegister("mem", memStorage(0))
+
+	var decoded []string
+	sw := oneof.NewSchemaWrapper().
+		MustRegister("s3", "https://acme.dev/schemas/storage/s3/v{version}.json", "2").
+		MustRegister("s3", "https://legacy.acme.dev/s3.json", "").
+		MustRegister("mem", "https://acme.dev/schemas/storage/mem.json", "")
+	sw.OnDecode = func(key, version string, v any) error {
+		decoded = append(decoded, key+"@"+version)
+		if version == "0" {
+			return errors.New("unsupported version")
+		}
+		return nil
+	}
+	opts := r.JSONOptions(&oneof.Config{WrapFunc: sw.Wrap})
+
+	t.Run("marshal writes the canonical URL", func(t *testing.T) {
+		in := schemaConfig{Storage: s3Storage{Bucket: "x", Version: "1"}}
+		b, err := json.Marshal(in, opts)
+		if err != nil {
+			t.Fatalf("error marshaling: %v", err)
+		}
+		want := `{"storage":{"$schema":"https://acme.dev/schemas/storage/s3/v2.json","bucket":"x"}}`
+		if string(b) != want {
+			t.Errorf("got:\n%s\nwant:\n%s", b, want)
+		}
+	})
+
+	t.Run("non-object values are nested", func(t *testing.T) {
+		b, err := json.Marshal(schemaConfig{Storage: memStorage(3)}, opts)
+		if err != nil {
+			t.Fatalf("error marshaling: %v", err)
+		}
+		want := `{"storage":{"$schema":"https://acme.dev/schemas/storage/mem.json","_value":3}}`
+		if string(b) != want {
+			t.Errorf("got:\n%s\nwant:\n%s", b, want)
+		}
+
+		var out schemaConfig
+		if err := json.Unmarshal(b, &out, opts); err != nil {
+			t.Fatalf("error unmarshaling: %v", err)
+		}
+		if out.Storage != memStorage(3) {
+			t.Errorf("got %#v, want %#v", out.Storage, memStorage(3))
+		}
+	})
+
+	tests := []struct {
+		name string
+		in   string
+		want schemaStorage
+	}{
+		{
+			name: "versioned pattern",
+			in:   `{"storage":{"$schema":"https://acme.dev/schemas/storage/s3/v3.json","bucket":"x"}}`,
+			want: s3Storage{Bucket: "x", Version: "3"},
+		},
+		{
+			name: "alternative pattern",
+			in:   `{"storage":{"bucket":"x","$schema":"https://legacy.acme.dev/s3.json"}}`,
+			want: s3Storage{Bucket: "x"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out schemaConfig
+			if err := json.Unmarshal([]byte(tt.in), &out, opts); err != nil {
+				t.Fatalf("error unmarshaling: %v", err)
+			}
+			if out.Storage != tt.want {
+				t.Errorf("got %#v, want %#v", out.Storage, tt.want)
+			}
+		})
+	}
+
+	t.Run("hook", func(t *testing.T) {
+		decoded = nil
+		in := `{"storage":{"$schema":"https://acme.dev/schemas/storage/s3/v5.json"}}`
+		var out schemaConfig
+		if err := json.Unmarshal([]byte(in), &out, opts); err != nil {
+			t.Fatalf("error unmarshaling: %v", err)
+		}
+		if len(decoded) != 1 || decoded[0] != "s3@5" {
+			t.Errorf("got hook calls %v, want [s3@5]", decoded)
+		}
+
+		in = `{"storage":{"$schema":"https://acme.dev/schemas/storage/s3/v0.json"}}`
+		if err := json.Unmarshal([]byte(in), &out, opts); err == nil {
+			t.Errorf("expected an error from the hook")
+		}
+	})
+
+	t.Run("unknown URL", func(t *testing.T) {
+		for _, u := range []string{
+			"https://acme.dev/schemas/storage/gcs/v1.json",
+			"https://acme.dev/schemas/storage/s3/v.json",
+			"https://acme.dev/schemas/storage/s3/v1/2.json",
+		} {
+			in := `{"storage":{"$schema":"` + u + `"}}`
+			var out schemaConfig
+			err := json.Unmarshal([]byte(in), &out, opts)
+			var unknown oneof.ErrUnknownDiscriminatorValue
+			if !errors.As(err, &unknown) {
+				t.Errorf("%s: got error %v, want ErrUnknownDiscriminatorValue", u, err)
+			}
+		}
+	})
+}
+
+func Test_SchemaWrapper_Register(t *testing.T) {
+	sw := oneof.NewSchemaWrapper()
+	if err := sw.Register("a", "https://x/{version}/{version}.json", "1"); err == nil {
+		t.Errorf("expected an error for several placeholders")
+	}
+	if err := sw.Register("a", "https://x/v{version}.json", ""); err == nil {
+		t.Errorf("expected an error for a missing canonical version")
+	}
+
+	// Using the wrapper freezes it
+	sw.MustRegister("a", "https://x/v{version}.json", "1")
+	sw.Wrap("a", nil)
+	if err := sw.Register("b", "https://y/v{version}.json", "1"); !errors.As(err, &oneof.ErrRegistryFrozen{}) {
+		t.Errorf("got error %v, want ErrRegistryFrozen", err)
+	}
+}
@@ -0,0 +1,138 @@
+package oneof
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// Shorthand is a compact, scalar JSON form of an option. For example, a config
+// file may contain
+//
+//	"backend": "redis://localhost"
+//
+// as a shorthand for
+//
+//	"backend": {"_type": "redis", "_value": {"url": "redis://localhost"}}
+//
+// Register a Shorthand for an option with [Registry.RegisterShorthand].
+type Shorthand[T any] struct {
+	// Parse is called by [Registry.UnmarshalFunc] with a JSON string or
+	// number found where a wrapped value of T was expected. If v is in
+	// the shorthand form, Parse returns the value it represents and
+	// ok = true. If v is not in the shorthand form, Parse returns
+	// ok = false, and the next registered Shorthand is tried.
+	//
+	// Parse must not retain v.
+	Parse func(v jsontext.Value) (t T, ok bool, err error)
+
+	// Format, if non-nil, is called by [Registry.MarshalFunc] for each
+	// value of the option, in the option's registered form: a value of a
+	// non-pointer option is passed by value, even where it is marshaled
+	// through a pointer. If t can be written in the shorthand form
+	// without losing information, Format returns that form (a JSON
+	// string or number) and ok = true. Otherwise, it returns ok = false,
+	// and t is wrapped as usual.
+	//
+	// For values to round trip, Parse must accept every value returned by
+	// Format, and no Shorthand registered before this one may accept it.
+	Format func(t T) (v jsontext.Value, ok bool)
+}
+
+// shorthandEntry is a [Shorthand] registered for the option key.
+type shorthandEntry[T any] struct {
+	key string
+	Shorthand[T]
+}
+
+// RegisterShorthand adds a shorthand form for the option registered under key.
+//
+// When [Registry.UnmarshalFunc] encounters a JSON string or number where it
+// expects a wrapped value of T, it calls the Parse func of each registered
+// Shorthand, in registration order, and uses the first value accepted. (With
+// [Config.ValuelessAsString], strings matching a discriminator value are
+// decoded as that option first.) If no Shorthand accepts the JSON, unmarshaling
+// fails, unless T is any, in which case the JSON is decoded by default.
+//
+// RegisterShorthand returns an error if r is frozen, if key has not been
+// registered, if key already has a Shorthand, or if s.Parse is nil.
+func (r *Registry[T]) RegisterShorthand(key string, s Shorthand[T]) error {
+	if s.Parse == nil {
+		return fmt.Errorf("cannot register shorthand for key %q: Parse is nil", key)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.frozen {
+		return ErrRegistryFrozen{key: key}
+	}
+	if _, ok := r.byKey[key]; !ok {
+		return fmt.Errorf("cannot register shorthand for key %q: key is not registered", key)
+	}
+	if _, ok := r.shorthandByKey[key]; ok {
+		return fmt.Errorf("cannot register shorthand for key %q: key already has a shorthand", key)
+	}
+
+	if r.shorthandByKey == nil {
+		r.shorthandByKey = map[string]int{}
+	}
+	r.shorthandByKey[key] = len(r.shorthands)
+	r.shorthands = append(r.shorthands, shorthandEntry[T]{
+		key:       key,
+		Shorthand: s,
+	})
+	return nil
+}
+
+// MustRegisterShorthand is like [Registry.RegisterShorthand], but panics if s
+// cannot be registered. It returns r, so that calls may be chained.
+func (r *Registry[T]) MustRegisterShorthand(key string, s Shorthand[T]) *Registry[T] {
+	if err := r.RegisterShorthand(key, s); err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// formatShorthand returns the shorthand form of t, an option registered under
+// key, if it has one.
+func (r *Registry[T]) formatShorthand(key string, t T) (jsontext.Value, bool, error) {
+	i, ok := r.shorthandByKey[key]
+	if !ok || r.shorthands[i].Format == nil {
+		return nil, false, nil
+	}
+
+	// A marshal func for T may be handed a pointer to a value
+	// of a non-pointer option (see [json.MarshalFuncV2])
+	if e, ok := r.lookupKey(key); ok && e.typ.Kind() != reflect.Ptr {
+		if rv := reflect.ValueOf(t); rv.Kind() == reflect.Ptr && rv.Type().Elem() == e.typ && !rv.IsNil() {
+			t = rv.Elem().Interface().(T)
+		}
+	}
+
+	v, ok := r.shorthands[i].Format(t)
+	if !ok {
+		return nil, false, nil
+	}
+	if k := v.Kind(); k != '"' && k != '0' {
+		return nil, false, fmt.Errorf("shorthand for key %s must be a JSON string or number, got %s", key, v)
+	}
+	return v, true, nil
+}
+
+// parseShorthand returns the value represented by the JSON string or number
+// v, according to the first registered [Shorthand] which accepts it.
+func (r *Registry[T]) parseShorthand(v jsontext.Value) (T, bool, error) {
+	for _, s := range r.shorthands {
+		t, ok, err := s.Parse(v)
+		if err != nil {
+			return t, false, fmt.Errorf("failed to parse shorthand for key %s: %w", s.key, err)
+		}
+		if ok {
+			return t, true, nil
+		}
+	}
+	var zero T
+	return zero, false, nil
+}
@@ -0,0 +1,172 @@
+package oneof_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dhoelle/oneof"
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+type redisBackend struct {
+	URL string `json:"url"`
+	DB  int    `json:"db,omitempty"`
+}
+
+func (b *redisBackend) String() string { return b.URL }
+
+var redisShorthand = oneof.Shorthand[fmt.Stringer]{
+	Parse: func(v jsontext.Value) (fmt.Stringer, bool, error) {
+		var s string
+		if err := json.Unmarshal(v, &s); err != nil || !strings.HasPrefix(s, "redis://") {
+			return nil, false, nil
+		}
+		return &redisBackend{URL: s}, true, nil
+	},
+	Format: func(t fmt.Stringer) (jsontext.Value, bool) {
+		b := t.(*redisBackend)
+		if b.DB != 0 {
+			return nil, false // the shorthand has no DB
+		}
+		v, err := json.Marshal(b.URL)
+		return v, err == nil
+	},
+}
+
+var exclamationShorthand = oneof.Shorthand[fmt.Stringer]{
+	Parse: func(v jsontext.Value) (fmt.Stringer, bool, error) {
+		if v.Kind() != '0' {
+			return nil, false, nil
+		}
+		var n int
+		if err := json.Unmarshal(v, &n); err != nil {
+			return nil, false, err
+		}
+		return ExclamationPointsStringer(n), true, nil
+	},
+}
+
+func Test_Shorthand(t *testing.T) {
+	r := oneof.NewRegistry[fmt.Stringer]().
+		MustRegister("redis", &redisBackend{}).
+		MustRegister("exclamation", ExclamationPointsStringer(0)).
+		MustRegister("literal", LiteralStringer("")).
+		MustRegisterShorthand("redis", redisShorthand).
+		MustRegisterShorthand("exclamation", exclamationShorthand)
+	opts := r.JSONOptions(nil)
+
+	in := []fmt.Stringer{
+		&redisBackend{URL: "redis://localhost"},
+		&redisBackend{URL: "redis://localhost", DB: 2},
+		ExclamationPointsStringer(3),
+		LiteralStringer("redis://localhost"),
+	}
+	b, err := json.Marshal(in, opts)
+	if err != nil {
+		t.Fatalf("error marshaling: %v", err)
+	}
+	// Only values with a Format func are written in shorthand
+	want := `["redis://localhost",` +
+		`{"_type":"redis","_value":{"url":"redis://localhost","db":2}},` +
+		`{"_type":"exclamation","_value":3},` +
+		`{"_type":"literal","_value":"redis://localhost"}]`
+	if string(b) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b, want)
+	}
+
+	var out []fmt.Stringer
+	if err := json.Unmarshal(b, &out, opts); err != nil {
+		t.Fatalf("error unmarshaling: %v", err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("got %#v, want %#v", out, in)
+	}
+
+	if err := json.Unmarshal([]byte(`[3]`), &out, opts); err != nil {
+		t.Fatalf("error unmarshaling: %v", err)
+	}
+	if want := []fmt.Stringer{ExclamationPointsStringer(3)}; !reflect.DeepEqual(out, want) {
+		t.Errorf("got %#v, want %#v", out, want)
+	}
+
+	// Scalars which no shorthand accepts are an error
+	var s fmt.Stringer
+	if err := json.Unmarshal([]byte(`"memcached://localhost"`), &s, opts); err == nil {
+		t.Errorf("got nil error, want error")
+	}
+	if err := json.Unmarshal([]byte(`1.5`), &s, opts); err == nil {
+		t.Errorf("got nil error, want error")
+	}
+}
+
+type memcachedBackend struct {
+	Addr string `json:"addr"`
+}
+
+func (b memcachedBackend) String() string { return b.Addr }
+
+func Test_ShorthandValueOption(t *testing.T) {
+	// Format is handed values of non-pointer options in their
+	// registered form, even where they are marshaled through
+	// a pointer (as slice elements are)
+	r := oneof.NewRegistry[fmt.Stringer]().
+		MustRegister("memcached", memcachedBackend{}).
+		MustRegisterShorthand("memcached", oneof.Shorthand[fmt.Stringer]{
+			Parse: func(v jsontext.Value) (fmt.Stringer, bool, error) {
+				var s string
+				if err := json.Unmarshal(v, &s); err != nil || !strings.HasPrefix(s, "memcached://") {
+					return nil, false, nil
+				}
+				return memcachedBackend{Addr: strings.TrimPrefix(s, "memcached://")}, true, nil
+			},
+			Format: func(t fmt.Stringer) (jsontext.Value, bool) {
+				v, err := json.Marshal("memcached://" + t.(memcachedBackend).Addr)
+				return v, err == nil
+			},
+		})
+	opts := r.JSONOptions(nil)
+
+	in := []fmt.Stringer{memcachedBackend{Addr: "localhost"}}
+	b, err := json.Marshal(in, opts)
+	if err != nil {
+		t.Fatalf("error marshaling: %v", err)
+	}
+	if want := `["memcached://localhost"]`; string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+
+	var out []fmt.Stringer
+	if err := json.Unmarshal(b, &out, opts); err != nil {
+		t.Fatalf("error unmarshaling: %v", err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("got %#v, want %#v", out, in)
+	}
+}
+
+func Test_RegisterShorthandErrors(t *testing.T) {
+	r := oneof.NewRegistry[fmt.Stringer]().
+		MustRegister("redis", &redisBackend{})
+
+	if err := r.RegisterShorthand("memcached", redisShorthand); err == nil {
+		t.Errorf("registering a shorthand for an unknown key: got nil error")
+	}
+	if err := r.RegisterShorthand("redis", oneof.Shorthand[fmt.Stringer]{}); err == nil {
+		t.Errorf("registering a shorthand without Parse: got nil error")
+	}
+	if err := r.RegisterShorthand("redis", redisShorthand); err != nil {
+		t.Errorf("error registering shorthand: %v", err)
+	}
+	if err := r.RegisterShorthand("redis", redisShorthand); err == nil {
+		t.Errorf("registering a second shorthand for a key: got nil error")
+	}
+
+	r.Freeze()
+	if err := r.RegisterShorthand("redis", redisShorthand); !errors.As(err, &oneof.ErrRegistryFrozen{}) {
+		t.Errorf("got error %v, want ErrRegistryFrozen", err)
+	}
+}
@@ -0,0 +1,197 @@
+package oneof
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// SiblingDiscriminator creates [json.Options] for the struct type P, one of
+// whose fields holds a oneof value of type T, and whose discriminator is
+// stored in a sibling member of the parent JSON object (like Jackson's
+// EXTERNAL_PROPERTY):
+//
+//	type Bucket struct {
+//	  Kind   string  `json:"kind"`
+//	  Config Storage `json:"config"`
+//	}
+//
+//	opts, err := oneof.SiblingDiscriminator[Bucket](r, "config", "kind", nil)
+//
+// encodes a Bucket like:
+//
+//	{
+//	  "kind": "s3",
+//	  "config": {"bucket": "x"}
+//	}
+//
+// valueKey is the JSON name of the field of type T, which is matched against
+// the name in the field's json tag, or else the Go field name. typeKey is the
+// JSON name of the discriminator. P may, but need not, have a field for the
+// discriminator: when marshaling, the discriminator is always set from the
+// registry, and when unmarshaling, it is decoded into that field like any
+// other member.
+//
+// The value under valueKey is not wrapped. A JSON null (or a missing
+// valueKey) unmarshals to a nil T.
+//
+// P may itself be an option of another [Registry]. Combine the marshalers of
+// that registry and of these options with [json.NewMarshalers], listing the
+// registry's first, so that it wraps the object encoded by these options (and
+// likewise for the unmarshalers).
+func SiblingDiscriminator[P any, T any](r *Registry[T], valueKey, typeKey string, cfg *Config) (json.Options, error) {
+	typ := reflect.TypeOf((*P)(nil)).Elem()
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot use %v with a sibling discriminator: not a struct type", typ)
+	}
+	field, ok := fieldByJSONName(typ, valueKey)
+	if !ok {
+		return nil, fmt.Errorf("cannot use %v with a sibling discriminator: no field for %q", typ, valueKey)
+	}
+	if typeT := reflect.TypeOf((*T)(nil)).Elem(); field.Type != typeT {
+		return nil, fmt.Errorf("cannot use %v with a sibling discriminator: field %s has type %v, want %v", typ, field.Name, field.Type, typeT)
+	}
+
+	r.Freeze()
+
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	types := r.typeIndex(cfg.StrictTypes)
+	replaceMissingTypeFunc := cfg.ReplaceMissingTypeFunc
+
+	// P may itself be an option of a Registry, whose funcs
+	// are skipped separately (see guard.go)
+	owner := &coderOwner{name: "sibling discriminator of " + typ.String()}
+
+	marshalFunc := func(enc *jsontext.Encoder, p P, jsonopts json.Options) error {
+		// See marshalFunc in marshal.go
+		if skipEncode(enc, owner) {
+			return json.SkipFunc
+		}
+
+		// Encode p by default, then replace the discriminator
+		// and value
+		var root jsonObject
+		err := marshalDefaultFrom(enc, owner, p, jsonopts, func(v jsontext.Value) error {
+			return root.parse(v)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to marshal %v: %w", typ, err)
+		}
+
+		fv := reflect.ValueOf(&p).Elem().FieldByIndex(field.Index)
+		if fv.IsNil() {
+			return root.encode(enc)
+		}
+		t := fv.Interface().(T)
+
+		discriminatorValue, ok := types.keyFor(t)
+		if !ok {
+			if replaceMissingTypeFunc == nil {
+				return ErrUnknownGoType{typ: fmt.Sprintf("%T", t)}
+			}
+			discriminatorValue = replaceMissingTypeFunc(t)
+		}
+		dv, err := json.Marshal(discriminatorValue)
+		if err != nil {
+			return fmt.Errorf("failed to encode discriminator value %s: %w", discriminatorValue, err)
+		}
+
+		err = marshalDefault(t, jsonopts, func(v jsontext.Value) error {
+			return root.set([]string{valueKey}, v.Clone())
+		})
+		if err != nil {
+			return fmt.Errorf("failed to marshal t: %w", err)
+		}
+
+		if i := root.index(typeKey); i >= 0 {
+			root[i].value = jsontext.Value(dv)
+		} else {
+			// Write the discriminator before the value
+			i := root.index(valueKey)
+			root = append(root[:i], append(jsonObject{{name: typeKey, value: jsontext.Value(dv)}}, root[i:]...)...)
+		}
+		return root.encode(enc)
+	}
+
+	unmarshalFunc := func(dec *jsontext.Decoder, p *P, jsonopts json.Options) error {
+		// See UnmarshalFunc in marshal.go
+		if skipDecode(dec, owner) {
+			return json.SkipFunc
+		}
+		if dec.PeekKind() != '{' {
+			return json.SkipFunc
+		}
+
+		var root jsonObject
+		if err := root.decode(dec); err != nil {
+			return err
+		}
+
+		// Decode the value according to the discriminator...
+		var t T
+		if v, ok := root.take([]string{valueKey}); ok && !isJSONNull(v) {
+			dv, ok := root.get([]string{typeKey})
+			if !ok {
+				return fmt.Errorf(`missing discriminator "%s"`, typeKey)
+			}
+			dvv, ok := dv.(jsontext.Value)
+			if !ok || dvv.Kind() != '"' {
+				return fmt.Errorf(`value for discriminator key "%s" must be a string`, typeKey)
+			}
+			var discriminatorValue string
+			if err := json.Unmarshal(dvv, &discriminatorValue); err != nil {
+				return fmt.Errorf("failed to decode discriminator: %w", err)
+			}
+			if _, ok := r.lookupKey(discriminatorValue); !ok {
+				return ErrUnknownDiscriminatorValue{v: discriminatorValue, token: dvv}
+			}
+			if err := r.decodeOption(&t, discriminatorValue, encodeJSONTree(v), nil, jsonopts); err != nil {
+				return err
+			}
+		}
+
+		// ...then decode the rest of p by default
+		if err := unmarshalDefaultFrom(dec, owner, encodeJSONTree(root), p, jsonopts); err != nil {
+			return fmt.Errorf("failed to unmarshal %v: %w", typ, err)
+		}
+		fv := reflect.ValueOf(p).Elem().FieldByIndex(field.Index)
+		fv.Set(reflect.ValueOf(&t).Elem())
+		return nil
+	}
+
+	return json.JoinOptions(
+		json.WithMarshalers(json.MarshalFuncV2(marshalFunc)),
+		json.WithUnmarshalers(json.UnmarshalFuncV2(unmarshalFunc)),
+	), nil
+}
+
+// fieldByJSONName returns the exported field of the struct type typ with the
+// JSON object member name name, according to its json tag or else its Go
+// name. Fields of embedded structs are not considered.
+func fieldByJSONName(typ reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tagName, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if tagName == "-" {
+			continue
+		}
+		if tagName == name || (tagName == "" && f.Name == name) {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// isJSONNull reports whether v, a jsonObject or jsontext.Value, is a JSON null.
+func isJSONNull(v any) bool {
+	jv, ok := v.(jsontext.Value)
+	return ok && jv.Kind() == 'n'
+}
@@ -0,0 +1,192 @@
+package oneof_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/dhoelle/oneof"
+	"github.com/go-json-experiment/json"
+)
+
+type storageConfig interface{ isStorage() }
+
+type s3Config struct {
+	Bucket string `json:"bucket"`
+}
+
+func (s3Config) isStorage() {}
+
+type diskConfig struct {
+	Path string `json:"path"`
+}
+
+func (*diskConfig) isStorage() {}
+
+type storage struct {
+	Name   string        `json:"name"`
+	Kind   string        `json:"kind"`
+	Config storageConfig `json:"config"`
+}
+
+// storageWithoutKind has no field for the discriminator
+type storageWithoutKind struct {
+	Config storageConfig `json:"config"`
+	Name   string        `json:"name"`
+}
+
+func Test_SiblingDiscriminator(t *testing.T) {
+	r := oneof.NewRegistry[storageConfig]().
+		MustRegister("s3", s3Config{}).
+		MustRegister("disk", &diskConfig{})
+
+	opts, err := oneof.SiblingDiscriminator[storage](r, "config", "kind", nil)
+	if err != nil {
+		t.Fatalf("error creating options: %v", err)
+	}
+
+	in := []storage{
+		{Name: "a", Config: s3Config{Bucket: "x"}},
+		{Name: "b", Kind: "stale", Config: &diskConfig{Path: "/tmp"}},
+		{Name: "c"},
+	}
+	b, err := json.Marshal(in, opts)
+	if err != nil {
+		t.Fatalf("error marshaling: %v", err)
+	}
+	want := `[{"name":"a","kind":"s3","config":{"bucket":"x"}},` +
+		`{"name":"b","kind":"disk","config":{"path":"/tmp"}},` +
+		`{"name":"c","kind":"","config":null}]`
+	if string(b) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b, want)
+	}
+
+	var out []storage
+	if err := json.Unmarshal(b, &out, opts); err != nil {
+		t.Fatalf("error unmarshaling: %v", err)
+	}
+	wantOut := []storage{
+		{Name: "a", Kind: "s3", Config: s3Config{Bucket: "x"}},
+		{Name: "b", Kind: "disk", Config: &diskConfig{Path: "/tmp"}},
+		{Name: "c"},
+	}
+	if !reflect.DeepEqual(out, wantOut) {
+		t.Errorf("got %#v, want %#v", out, wantOut)
+	}
+
+	// The discriminator may follow the value
+	var s storage
+	if err := json.Unmarshal([]byte(`{"config":{"path":"/"},"kind":"disk"}`), &s, opts); err != nil {
+		t.Fatalf("error unmarshaling: %v", err)
+	}
+	if want := (storage{Kind: "disk", Config: &diskConfig{Path: "/"}}); !reflect.DeepEqual(s, want) {
+		t.Errorf("got %#v, want %#v", s, want)
+	}
+
+	err = json.Unmarshal([]byte(`{"kind":"tape","config":{}}`), &s, opts)
+	if !errors.As(err, &oneof.ErrUnknownDiscriminatorValue{}) {
+		t.Errorf("got error %v, want ErrUnknownDiscriminatorValue", err)
+	}
+	if err := json.Unmarshal([]byte(`{"config":{}}`), &s, opts); err == nil {
+		t.Errorf("missing discriminator: got nil error")
+	}
+}
+
+func Test_SiblingDiscriminatorWithoutField(t *testing.T) {
+	r := oneof.NewRegistry[storageConfig]().
+		MustRegister("s3", s3Config{})
+
+	opts, err := oneof.SiblingDiscriminator[storageWithoutKind](r, "config", "kind", nil)
+	if err != nil {
+		t.Fatalf("error creating options: %v", err)
+	}
+
+	in := storageWithoutKind{Name: "a", Config: s3Config{Bucket: "x"}}
+	b, err := json.Marshal(in, opts)
+	if err != nil {
+		t.Fatalf("error marshaling: %v", err)
+	}
+	if want := `{"kind":"s3","config":{"bucket":"x"},"name":"a"}`; string(b) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b, want)
+	}
+
+	var out storageWithoutKind
+	if err := json.Unmarshal(b, &out, opts); err != nil {
+		t.Fatalf("error unmarshaling: %v", err)
+	}
+	if out != in {
+		t.Errorf("got %#v, want %#v", out, in)
+	}
+}
+
+func Test_SiblingDiscriminatorErrors(t *testing.T) {
+	r := oneof.NewRegistry[storageConfig]()
+	if _, err := oneof.SiblingDiscriminator[storage](r, "missing", "kind", nil); err == nil {
+		t.Errorf("unknown field: got nil error")
+	}
+	if _, err := oneof.SiblingDiscriminator[storage](r, "name", "kind", nil); err == nil {
+		t.Errorf("field of the wrong type: got nil error")
+	}
+	if _, err := oneof.SiblingDiscriminator[*storage](r, "config", "kind", nil); err == nil {
+		t.Errorf("non-struct type: got nil error")
+	}
+}
+
+type resource interface{ isResource() }
+
+func (storage) isResource() {}
+
+type queue struct {
+	Name string `json:"name"`
+}
+
+func (queue) isResource() {}
+
+func Test_SiblingDiscriminatorInRegistry(t *testing.T) {
+	storages := oneof.NewRegistry[storageConfig]().
+		MustRegister("s3", s3Config{}).
+		MustRegister("disk", &diskConfig{})
+	resources := oneof.NewRegistry[resource]().
+		MustRegister("storage", storage{}).
+		MustRegister("queue", queue{})
+
+	sibling, err := oneof.SiblingDiscriminator[storage](storages, "config", "kind", nil)
+	if err != nil {
+		t.Fatalf("error creating options: %v", err)
+	}
+	siblingMarshalers, _ := json.GetOption(sibling, json.WithMarshalers)
+	siblingUnmarshalers, _ := json.GetOption(sibling, json.WithUnmarshalers)
+
+	// The registry wraps the value encoded by the sibling
+	// discriminator's funcs, so its funcs come first
+	opts := json.JoinOptions(
+		json.WithMarshalers(json.NewMarshalers(resources.MarshalFunc(nil), siblingMarshalers)),
+		json.WithUnmarshalers(json.NewUnmarshalers(resources.UnmarshalFunc(nil), siblingUnmarshalers)),
+	)
+
+	in := []resource{
+		storage{Name: "a", Config: s3Config{Bucket: "x"}},
+		queue{Name: "b"},
+	}
+	b, err := json.Marshal(in, opts)
+	if err != nil {
+		t.Fatalf("error marshaling: %v", err)
+	}
+	want := `[{"_type":"storage","_value":{"name":"a","kind":"s3","config":{"bucket":"x"}}},` +
+		`{"_type":"queue","_value":{"name":"b"}}]`
+	if string(b) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b, want)
+	}
+
+	var out []resource
+	if err := json.Unmarshal(b, &out, opts); err != nil {
+		t.Fatalf("error unmarshaling: %v", err)
+	}
+	wantOut := []resource{
+		storage{Name: "a", Kind: "s3", Config: s3Config{Bucket: "x"}},
+		queue{Name: "b"},
+	}
+	if !reflect.DeepEqual(out, wantOut) {
+		t.Errorf("got %#v, want %#v", out, wantOut)
+	}
+}
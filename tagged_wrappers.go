@@ -0,0 +1,285 @@
+package oneof
+
+import (
+	"fmt"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// WrapExternal wraps a oneof value in an "externally tagged" JSON object,
+// whose only key is the discriminator and whose value is the encoded Go
+// value:
+//
+//	{
+//	  "url.URL": {
+//	    "Scheme": "https",
+//	    "Host": "example.com"
+//	  }
+//	}
+//
+// This is the default representation of enums in Rust's serde.
+func WrapExternal(typ string, v jsontext.Value) WrappedValue {
+	return externalWrappedValue{
+		typ:   typ,
+		value: v,
+	}
+}
+
+type externalWrappedValue struct {
+	typ   string
+	value jsontext.Value
+}
+
+func (w externalWrappedValue) Type() string          { return w.typ }
+func (w externalWrappedValue) Value() jsontext.Value { return w.value }
+
+func (w externalWrappedValue) MarshalJSONV2(enc *jsontext.Encoder, opts json.Options) error {
+	if err := enc.WriteToken(jsontext.ObjectStart); err != nil {
+		return fmt.Errorf("failed to write object start token: %w", err)
+	}
+	if err := enc.WriteToken(jsontext.String(w.typ)); err != nil {
+		return fmt.Errorf("failed to write discriminator token %s: %w", w.typ, err)
+	}
+	if err := enc.WriteValue(valueOrNull(w.value)); err != nil {
+		return fmt.Errorf("failed to write value: %w", err)
+	}
+	if err := enc.WriteToken(jsontext.ObjectEnd); err != nil {
+		return fmt.Errorf("failed to write object end token: %w", err)
+	}
+	return nil
+}
+
+func (w *externalWrappedValue) UnmarshalJSONV2(dec *jsontext.Decoder, opts json.Options) error {
+	if k := dec.PeekKind(); k != '{' {
+		return fmt.Errorf("expected object start, but encountered %v", k)
+	}
+	if _, err := dec.ReadToken(); err != nil {
+		return fmt.Errorf("failed to read object start token: %w", err)
+	}
+	if dec.PeekKind() == '}' {
+		return fmt.Errorf("expected a single key, but found an empty object")
+	}
+	tok, err := dec.ReadToken()
+	if err != nil {
+		return fmt.Errorf("failed to read discriminator token: %w", err)
+	}
+	typ := tok.String()
+	v, err := dec.ReadValue()
+	if err != nil {
+		return fmt.Errorf("failed to read value: %w", err)
+	}
+	value := v.Clone()
+	if dec.PeekKind() != '}' {
+		return fmt.Errorf("expected a single key, but found more than one")
+	}
+	if _, err := dec.ReadToken(); err != nil {
+		return fmt.Errorf("failed to read object end token: %w", err)
+	}
+
+	w.typ = typ
+	w.value = value
+	return nil
+}
+
+// AdjacentWrapper builds a WrapFunc which wraps a oneof value in an
+// "adjacently tagged" JSON object, with exactly two keys: one for the
+// discriminator, and one for the encoded Go value.
+//
+//	cfg := &oneof.Config{
+//	  WrapFunc: oneof.AdjacentWrapper{TagKey: "t", ContentKey: "c"}.Wrap,
+//	}
+//
+// produces JSON like:
+//
+//	{
+//	  "t": "crypto.Hash",
+//	  "c": 5
+//	}
+//
+// Unlike [WrapNested] and [CustomValueWrapper], the content key is always
+// written, and unmarshaling rejects objects with any other keys. An object
+// without the content key unmarshals to the zero value of the option.
+type AdjacentWrapper struct {
+	// TagKey is the JSON object key for the discriminator.
+	// If empty, defaults to "_type".
+	TagKey string
+
+	// ContentKey is the JSON object key for the encoded Go value.
+	// If empty, defaults to "_value".
+	ContentKey string
+}
+
+// Wrap is a WrapFunc which can be used as the WrapFunc in [Config].
+func (a AdjacentWrapper) Wrap(typ string, v jsontext.Value) WrappedValue {
+	w := adjacentWrappedValue{
+		tagKey:     defaultTypeDiscriminatorKey,
+		contentKey: defaultNestedValueKey,
+		typ:        typ,
+		value:      v,
+	}
+	if a.TagKey != "" {
+		w.tagKey = a.TagKey
+	}
+	if a.ContentKey != "" {
+		w.contentKey = a.ContentKey
+	}
+	return w
+}
+
+type adjacentWrappedValue struct {
+	tagKey     string
+	contentKey string
+	typ        string
+	value      jsontext.Value
+}
+
+func (w adjacentWrappedValue) Type() string                { return w.typ }
+func (w adjacentWrappedValue) Value() jsontext.Value       { return w.value }
+func (w adjacentWrappedValue) discriminatorMember() string { return w.tagKey }
+
+func (w adjacentWrappedValue) MarshalJSONV2(enc *jsontext.Encoder, opts json.Options) error {
+	if err := enc.WriteToken(jsontext.ObjectStart); err != nil {
+		return fmt.Errorf("failed to write object start token: %w", err)
+	}
+	if err := enc.WriteToken(jsontext.String(w.tagKey)); err != nil {
+		return fmt.Errorf("failed to write tag key token %s: %w", w.tagKey, err)
+	}
+	if err := enc.WriteToken(jsontext.String(w.typ)); err != nil {
+		return fmt.Errorf("failed to write discriminator token %s: %w", w.typ, err)
+	}
+	if err := enc.WriteToken(jsontext.String(w.contentKey)); err != nil {
+		return fmt.Errorf("failed to write content key token %s: %w", w.contentKey, err)
+	}
+	if err := enc.WriteValue(valueOrNull(w.value)); err != nil {
+		return fmt.Errorf("failed to write value: %w", err)
+	}
+	if err := enc.WriteToken(jsontext.ObjectEnd); err != nil {
+		return fmt.Errorf("failed to write object end token: %w", err)
+	}
+	return nil
+}
+
+func (w *adjacentWrappedValue) UnmarshalJSONV2(dec *jsontext.Decoder, opts json.Options) error {
+	if k := dec.PeekKind(); k != '{' {
+		return fmt.Errorf("expected object start, but encountered %v", k)
+	}
+	if _, err := dec.ReadToken(); err != nil {
+		return fmt.Errorf("failed to read object start token: %w", err)
+	}
+
+	foundTag := false
+	for dec.PeekKind() != '}' {
+		tok, err := dec.ReadToken()
+		if err != nil {
+			return fmt.Errorf("failed to read object key token: %w", err)
+		}
+		switch k := tok.String(); k {
+		case w.tagKey:
+			if dec.PeekKind() != '"' {
+				return fmt.Errorf(`value for tag key "%s" must be a string (got %v)`, w.tagKey, dec.PeekKind())
+			}
+			tok, err := dec.ReadToken()
+			if err != nil {
+				return fmt.Errorf("failed to read discriminator token: %w", err)
+			}
+			w.typ = tok.String()
+			foundTag = true
+		case w.contentKey:
+			v, err := dec.ReadValue()
+			if err != nil {
+				return fmt.Errorf("failed to read value: %w", err)
+			}
+			w.value = v.Clone()
+		default:
+			return fmt.Errorf(`unexpected key "%s"`, k)
+		}
+	}
+	if _, err := dec.ReadToken(); err != nil {
+		return fmt.Errorf("failed to read object end token: %w", err)
+	}
+
+	if !foundTag {
+		return fmt.Errorf(`missing tag key "%s"`, w.tagKey)
+	}
+	return nil
+}
+
+// WrapTuple wraps a oneof value in a two-element JSON array, whose first
+// element is the discriminator and whose second element is the encoded Go
+// value:
+//
+//	["crypto.Hash", 5]
+//
+// When unmarshaling, a one-element array (e.g., ["crypto.Hash"]) unmarshals
+// to the zero value of the option.
+func WrapTuple(typ string, v jsontext.Value) WrappedValue {
+	return tupleWrappedValue{
+		typ:   typ,
+		value: v,
+	}
+}
+
+type tupleWrappedValue struct {
+	typ   string
+	value jsontext.Value
+}
+
+func (w tupleWrappedValue) Type() string          { return w.typ }
+func (w tupleWrappedValue) Value() jsontext.Value { return w.value }
+
+func (w tupleWrappedValue) MarshalJSONV2(enc *jsontext.Encoder, opts json.Options) error {
+	if err := enc.WriteToken(jsontext.ArrayStart); err != nil {
+		return fmt.Errorf("failed to write array start token: %w", err)
+	}
+	if err := enc.WriteToken(jsontext.String(w.typ)); err != nil {
+		return fmt.Errorf("failed to write discriminator token %s: %w", w.typ, err)
+	}
+	if err := enc.WriteValue(valueOrNull(w.value)); err != nil {
+		return fmt.Errorf("failed to write value: %w", err)
+	}
+	if err := enc.WriteToken(jsontext.ArrayEnd); err != nil {
+		return fmt.Errorf("failed to write array end token: %w", err)
+	}
+	return nil
+}
+
+func (w *tupleWrappedValue) UnmarshalJSONV2(dec *jsontext.Decoder, opts json.Options) error {
+	if k := dec.PeekKind(); k != '[' {
+		return fmt.Errorf("expected array start, but encountered %v", k)
+	}
+	if _, err := dec.ReadToken(); err != nil {
+		return fmt.Errorf("failed to read array start token: %w", err)
+	}
+	if k := dec.PeekKind(); k != '"' {
+		return fmt.Errorf("expected a string discriminator, but encountered %v", k)
+	}
+	tok, err := dec.ReadToken()
+	if err != nil {
+		return fmt.Errorf("failed to read discriminator token: %w", err)
+	}
+	w.typ = tok.String()
+
+	if dec.PeekKind() != ']' {
+		v, err := dec.ReadValue()
+		if err != nil {
+			return fmt.Errorf("failed to read value: %w", err)
+		}
+		w.value = v.Clone()
+	}
+	if dec.PeekKind() != ']' {
+		return fmt.Errorf("expected at most two array elements")
+	}
+	if _, err := dec.ReadToken(); err != nil {
+		return fmt.Errorf("failed to read array end token: %w", err)
+	}
+	return nil
+}
+
+// valueOrNull returns v, or a JSON null if v is empty.
+func valueOrNull(v jsontext.Value) jsontext.Value {
+	if len(v) == 0 {
+		return jsontext.Value("null")
+	}
+	return v
+}
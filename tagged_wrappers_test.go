@@ -0,0 +1,122 @@
+package oneof_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/dhoelle/oneof"
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+func Test_TaggedWrappers(t *testing.T) {
+	tests := []struct {
+		name     string
+		wrapFunc func(string, jsontext.Value) oneof.WrappedValue
+		want     string
+	}{
+		{
+			name:     "external",
+			wrapFunc: oneof.WrapExternal,
+			want:     `{"join":{"a":{"literal":"Hello"},"b":{"exclamation":3},"separator":" "}}`,
+		},
+		{
+			name:     "adjacent",
+			wrapFunc: oneof.AdjacentWrapper{TagKey: "t", ContentKey: "c"}.Wrap,
+			want:     `{"t":"join","c":{"a":{"t":"literal","c":"Hello"},"b":{"t":"exclamation","c":3},"separator":" "}}`,
+		},
+		{
+			name:     "adjacent with default keys",
+			wrapFunc: oneof.AdjacentWrapper{}.Wrap,
+			want:     `{"_type":"join","_value":{"a":{"_type":"literal","_value":"Hello"},"b":{"_type":"exclamation","_value":3},"separator":" "}}`,
+		},
+		{
+			name:     "tuple",
+			wrapFunc: oneof.WrapTuple,
+			want:     `["join",{"a":["literal","Hello"],"b":["exclamation",3],"separator":" "}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := oneof.NewRegistry[fmt.Stringer]().
+				MustRegister("literal", LiteralStringer("")).
+				MustRegister("join", JoinStringer{}).
+				MustRegister("exclamation", ExclamationPointsStringer(0))
+			opts := json.JoinOptions(
+				r.JSONOptions(&oneof.Config{WrapFunc: tt.wrapFunc}),
+				json.Deterministic(true),
+			)
+
+			var in fmt.Stringer = JoinStringer{
+				A:         LiteralStringer("Hello"),
+				Separator: " ",
+				B:         ExclamationPointsStringer(3),
+			}
+			b, err := json.Marshal(in, opts)
+			if err != nil {
+				t.Fatalf("error marshaling: %v", err)
+			}
+			if got := string(b); got != tt.want {
+				t.Errorf("got JSON:\n%s\nwant:\n%s", got, tt.want)
+			}
+
+			var out fmt.Stringer
+			if err := json.Unmarshal(b, &out, opts); err != nil {
+				t.Fatalf("error unmarshaling: %v", err)
+			}
+			if !reflect.DeepEqual(out, in) {
+				t.Errorf("got %#v, want %#v", out, in)
+			}
+		})
+	}
+}
+
+func Test_TaggedWrappersUnmarshal(t *testing.T) {
+	r := oneof.NewRegistry[fmt.Stringer]().
+		MustRegister("literal", LiteralStringer("")).
+		MustRegister("exclamation", ExclamationPointsStringer(0))
+
+	tests := []struct {
+		name     string
+		wrapFunc func(string, jsontext.Value) oneof.WrappedValue
+		in       string
+		want     fmt.Stringer
+		wantErr  bool
+	}{
+		{"external", oneof.WrapExternal, `{"exclamation":2}`, ExclamationPointsStringer(2), false},
+		{"external empty object", oneof.WrapExternal, `{}`, nil, true},
+		{"external extra key", oneof.WrapExternal, `{"exclamation":2,"literal":"a"}`, nil, true},
+		{"external not an object", oneof.WrapExternal, `["exclamation",2]`, nil, true},
+		{"adjacent content first", oneof.AdjacentWrapper{}.Wrap, `{"_value":2,"_type":"exclamation"}`, ExclamationPointsStringer(2), false},
+		{"adjacent missing content", oneof.AdjacentWrapper{}.Wrap, `{"_type":"exclamation"}`, ExclamationPointsStringer(0), false},
+		{"adjacent missing tag", oneof.AdjacentWrapper{}.Wrap, `{"_value":2}`, nil, true},
+		{"adjacent unknown key", oneof.AdjacentWrapper{}.Wrap, `{"_type":"exclamation","_value":2,"x":1}`, nil, true},
+		{"adjacent non-string tag", oneof.AdjacentWrapper{}.Wrap, `{"_type":1,"_value":2}`, nil, true},
+		{"tuple", oneof.WrapTuple, `["literal","a"]`, LiteralStringer("a"), false},
+		{"tuple without value", oneof.WrapTuple, `["literal"]`, LiteralStringer(""), false},
+		{"tuple too long", oneof.WrapTuple, `["literal","a","b"]`, nil, true},
+		{"tuple non-string tag", oneof.WrapTuple, `[1,"a"]`, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := r.JSONOptions(&oneof.Config{WrapFunc: tt.wrapFunc})
+			var got fmt.Stringer
+			err := json.Unmarshal([]byte(tt.in), &got, opts)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got nil error, want error (value: %#v)", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error unmarshaling: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}